@@ -3,26 +3,37 @@ package adapter
 import (
 	"bytes"
 	"errors"
+	"expvar"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"path/filepath"
-	"plugin"
 	gotemplate "text/template"
 
 	"golang.org/x/sync/errgroup"
 
+	"github.com/skilld-labs/http-event-adapter/auth"
+	"github.com/skilld-labs/http-event-adapter/extension"
 	"github.com/skilld-labs/http-event-adapter/log"
 
 	"github.com/skilld-labs/http-event-adapter/configuration"
 
 	"github.com/skilld-labs/http-event-adapter/format"
+	"github.com/skilld-labs/http-event-adapter/router"
+	"github.com/skilld-labs/http-event-adapter/schema"
 	"github.com/skilld-labs/http-event-adapter/template"
+	"github.com/skilld-labs/http-event-adapter/transform"
 	"github.com/skilld-labs/http-event-adapter/writer"
 )
 
 const (
 	defaultBatchInterval = time.Second
+	maxConcurrentOutputs = 64
 )
 
 var (
@@ -30,31 +41,57 @@ var (
 )
 
 type AdapterConfiguration struct {
-	Logger          log.Logger
-	Config          configuration.Provider
-	FormatterByName func(string) (format.Formatter, error)
+	Logger log.Logger
+	Config configuration.Provider
+	// FormatterByName creates the formatter of the given name reading its options
+	// from the given configuration.
+	FormatterByName func(string, configuration.Provider) (format.Formatter, error)
 	WriterByName    func(string) (writer.Writer, error)
 }
 
 type Adapter struct {
 	logger          log.Logger
 	config          configuration.Provider
-	formatterByName func(string) (format.Formatter, error)
+	formatterByName func(string, configuration.Provider) (format.Formatter, error)
 	writerByName    func(string) (writer.Writer, error)
 }
 
+const (
+	// OutputFormatText renders the output template as is.
+	OutputFormatText = "text"
+	// OutputFormatJSON reads the output template as a YAML or JSON skeleton whose
+	// string values are templates, and renders a valid JSON document.
+	OutputFormatJSON = "json"
+)
+
 type EventConfiguration struct {
-	InputFormat       string              `config:"inputFormat"`
-	OutputTemplate    string              `config:"outputTemplate"`
-	OutputWriter      string              `config:"outputWriter"`
-	OutputChannel     string              `config:"outputChannel"`
-	SingleInputEvent  bool                `config:"singleInputEvent"`
-	SingleOutputEvent bool                `config:"singleOutputEvent"`
-	ChrootPath        string              `config:"chrootPath"`
-	ExtendedFunctions map[string][]string `config:"extendedFunctions"`
-	BatchSize         int64               `config:"batchSize"`
-	BatchInterval     string              `config:"batchInterval"`
-	batchInterval     time.Duration
+	Methods               []string                          `config:"methods"`
+	Auth                  *auth.Configuration               `config:"auth"`
+	MaxBodySize           string                            `config:"maxBodySize"`
+	InputFormat           string                            `config:"inputFormat"`
+	InputOptions          map[string]map[string]interface{} `config:"inputOptions"`
+	InputSchema           string                            `config:"inputSchema"`
+	Sync                  bool                              `config:"sync"`
+	Stream                bool                              `config:"stream"`
+	OutputTemplate        string                            `config:"outputTemplate"`
+	OutputFormat          string                            `config:"outputFormat"`
+	OutputWriter          string                            `config:"outputWriter"`
+	OutputChannel         string                            `config:"outputChannel"`
+	OutputSchema          string                            `config:"outputSchema"`
+	OutputEncoding        string                            `config:"outputEncoding"`
+	OutputEncodingOptions map[string]interface{}            `config:"outputEncodingOptions"`
+	DeadLetterWriter      string                            `config:"deadLetterWriter"`
+	DeadLetterChannel     string                            `config:"deadLetterChannel"`
+	SingleInputEvent      bool                              `config:"singleInputEvent"`
+	SingleOutputEvent     bool                              `config:"singleOutputEvent"`
+	ChrootPath            string                            `config:"chrootPath"`
+	ExtendedFunctions     map[string][]string               `config:"extendedFunctions"`
+	ExternalFunctions     map[string][]string               `config:"externalFunctions"`
+	Transform             *transform.Configuration          `config:"transform"`
+	BatchSize             int64                             `config:"batchSize"`
+	BatchInterval         string                            `config:"batchInterval"`
+	batchInterval         time.Duration
+	formatConfig          configuration.Provider
 }
 
 func NewAdapter(cfg *AdapterConfiguration) (*Adapter, error) {
@@ -66,36 +103,157 @@ func NewAdapter(cfg *AdapterConfiguration) (*Adapter, error) {
 	}, nil
 }
 
-func (a *Adapter) AdaptEvent(eventCfg *EventConfiguration) (func([]byte) error, error) {
+func (a *Adapter) AdaptEvent(eventCfg *EventConfiguration) (func(*router.Request) error, error) {
+	var deadLetter writer.Writer
+	if eventCfg.DeadLetterWriter != "" {
+		var err error
+		if deadLetter, err = a.writerByName(eventCfg.DeadLetterWriter); err != nil {
+			return nil, err
+		}
+	}
 	writer, err := a.writerByName(eventCfg.OutputWriter)
 	if err != nil {
 		return nil, err
 	}
-	formatter, err := a.formatterByName(eventCfg.InputFormat)
+	if eventCfg.formatConfig, err = a.formatConfig(eventCfg); err != nil {
+		return nil, err
+	}
+	var formatter format.Formatter
+	if eventCfg.InputFormat != format.Auto {
+		formatter, err = a.formatterByName(eventCfg.InputFormat, eventCfg.formatConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+	funcs, err := a.getFuncs(eventCfg)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := a.getOutputTemplate(eventCfg, funcs)
 	if err != nil {
 		return nil, err
 	}
-	tmpl, err := a.getOutputTemplate(eventCfg)
+	channelTmpl, err := a.getChannelTemplate(eventCfg, funcs)
 	if err != nil {
 		return nil, err
 	}
-	channelTmpl, err := a.getChannelTemplate(eventCfg)
-	batchInterval := defaultBatchInterval
+	var inputSchema *schema.Schema
+	if eventCfg.InputSchema != "" {
+		if inputSchema, err = schema.Load(eventCfg.InputSchema); err != nil {
+			return nil, fmt.Errorf("inputSchema: %s", err.Error())
+		}
+	}
+	var outputSchema *schema.Schema
+	if eventCfg.OutputSchema != "" {
+		if outputSchema, err = schema.Load(eventCfg.OutputSchema); err != nil {
+			return nil, fmt.Errorf("outputSchema: %s", err.Error())
+		}
+	}
+	var encoder format.Encoder
+	if eventCfg.OutputEncoding != "" {
+		if encoder, err = a.getEncoder(eventCfg); err != nil {
+			return nil, err
+		}
+	}
+	var transformer *transform.Transformer
+	if eventCfg.Transform != nil {
+		if transformer, err = transform.NewTransformer(eventCfg.Transform); err != nil {
+			return nil, fmt.Errorf("transform: %s", err.Error())
+		}
+	}
 	if eventCfg.BatchSize > 0 {
-		if d, err := time.ParseDuration(eventCfg.BatchInterval); err == nil {
-			batchInterval = d
-		} else {
-			a.logger.Err("error while parsing batch interval (%s) using default batch interval instead (%s)", err.Error(), defaultBatchInterval.String())
+		eventCfg.batchInterval = defaultBatchInterval
+		if eventCfg.BatchInterval != "" {
+			if eventCfg.batchInterval, err = time.ParseDuration(eventCfg.BatchInterval); err != nil {
+				return nil, fmt.Errorf("batchInterval: %s", err.Error())
+			}
 		}
-		eventCfg.batchInterval = batchInterval
 		a.logger.Debug("batch is enable (output channel %s), batch size: %d, batch interval: %s", eventCfg.OutputChannel, eventCfg.BatchSize, eventCfg.batchInterval.String())
 	}
-	return func(event []byte) error {
+	return func(req *router.Request) error {
+		var err error
+		tmpl, channelTmpl := tmpl, channelTmpl
+		if req.Principal != nil {
+			if tmpl, err = withPrincipal(tmpl, req.Principal); err != nil {
+				return err
+			}
+			if channelTmpl, err = withPrincipal(channelTmpl, req.Principal); err != nil {
+				return err
+			}
+		}
+		var render func(chan output) error
+		var skipped *format.SkippedError
+		if req.Reader != nil && req.Method != http.MethodGet {
+			each, err := a.streamElements(req, eventCfg, formatter, inputSchema, transformer)
+			if err != nil {
+				return &router.Error{Status: http.StatusBadRequest, Err: err}
+			}
+			render = func(outputs chan output) error {
+				return a.outputsFromElements(each, eventCfg, tmpl, channelTmpl, outputs)
+			}
+		} else {
+			elem, err := a.inputFromRequest(req, eventCfg, formatter)
+			if err != nil && (elem == nil || !errors.As(err, &skipped)) {
+				return &router.Error{Status: http.StatusBadRequest, Err: err}
+			}
+			if inputSchema != nil {
+				if elem, err = a.checkInput(inputSchema, eventCfg, elem); err != nil {
+					return err
+				}
+				if elem == nil {
+					return nil
+				}
+			}
+			if transformer != nil {
+				if elem, err = transformInput(elem, eventCfg, transformer); err != nil {
+					return err
+				}
+				if elem == nil {
+					a.logger.Debug("all input elements have been dropped by transform (output channel %s)", eventCfg.OutputChannel)
+					return nil
+				}
+			}
+			render = func(outputs chan output) error {
+				return a.outputsFromEvent(elem, eventCfg, tmpl, channelTmpl, outputs)
+			}
+		}
+		var renderErr, writeErr, rejectErr error
 		outputs := make(chan (output))
-		go a.outputsFromEvent(event, eventCfg, formatter, tmpl, channelTmpl, outputs)
+		go func() {
+			defer close(outputs)
+			renderErr = render(outputs)
+		}()
 		for o := range outputs {
+			if err = a.checkOutput(outputSchema, encoder, &o); err != nil {
+				a.logger.Err("output rejected (output channel %s): %s", o.channel, err.Error())
+				if rejectErr == nil {
+					rejectErr = &router.Error{Status: http.StatusUnprocessableEntity, Err: fmt.Errorf("output rejected: %s", err.Error())}
+				}
+				if deadLetter != nil {
+					if err = deadLetter.Write(eventCfg.DeadLetterChannel, o.body); err != nil {
+						a.logger.Err(err.Error())
+					}
+				}
+				continue
+			}
 			if err = writer.Write(o.channel, o.body); err != nil {
 				a.logger.Err(err.Error())
+				writeErr = err
+			}
+		}
+		if renderErr != nil && !errors.As(renderErr, &skipped) {
+			return renderErr
+		}
+		if eventCfg.Sync || eventCfg.Stream {
+			if writeErr != nil {
+				return writeErr
+			}
+			if rejectErr != nil {
+				return rejectErr
+			}
+			if skipped != nil {
+				// the request has been processed, the client is told what was skipped
+				return &router.Error{Status: http.StatusOK, Err: skipped, Details: map[string]interface{}{"skipped": skipped.Errors}}
 			}
 		}
 		return nil
@@ -107,144 +265,451 @@ type output struct {
 	body    []byte
 }
 
-func (a *Adapter) outputsFromEvent(event []byte, eventCfg *EventConfiguration, formatter format.Formatter, tmpl *gotemplate.Template, channelTmpl *gotemplate.Template, outputs chan (output)) {
-	g := errgroup.Group{}
-	var err error
-	var elem interface{}
-	if eventCfg.SingleInputEvent {
-		elem, err = formatter.FormatSingle(event)
-		if err != nil {
-			a.logger.Err(err.Error())
-			close(outputs)
+// InputViolation is a part of an input element which does not match the input schema.
+type InputViolation struct {
+	// Index is the position of the element in the input document.
+	Index int `json:"index"`
+	schema.Violation
+}
+
+const inputViolationsVar = "inputSchemaViolations"
+
+var (
+	inputViolationsOnce sync.Once
+	inputViolationsMap  *expvar.Map
+)
+
+// inputViolations returns the expvar counting the input elements rejected by the
+// input schema, by output channel. It is registered on first use, or reused if
+// already published.
+func inputViolations() *expvar.Map {
+	inputViolationsOnce.Do(func() {
+		if m, ok := expvar.Get(inputViolationsVar).(*expvar.Map); ok {
+			inputViolationsMap = m
 			return
 		}
-	} else {
-		elem, err = formatter.FormatMultiple(event)
+		inputViolationsMap = expvar.NewMap(inputViolationsVar)
+	})
+	return inputViolationsMap
+}
+
+// inputViolationsError is the error answering requests whose input elements do not
+// match the input schema.
+func inputViolationsError(violations []InputViolation) error {
+	return &router.Error{
+		Status:  http.StatusUnprocessableEntity,
+		Err:     fmt.Errorf("%d input violations", len(violations)),
+		Details: map[string]interface{}{"violations": violations},
+	}
+}
+
+// checkInput validates the input elements against the input schema. Synchronous
+// and stream events reject the whole input if an element is invalid, asynchronous
+// ones only drop the invalid elements.
+func (a *Adapter) checkInput(inputSchema *schema.Schema, eventCfg *EventConfiguration, elem interface{}) (interface{}, error) {
+	elems, isList := elem.([]interface{})
+	if !isList {
+		elems = []interface{}{elem}
+	}
+	var violations []InputViolation
+	valid := make([]interface{}, 0, len(elems))
+	for i, e := range elems {
+		vv, err := inputSchema.Validate(e)
 		if err != nil {
-			a.logger.Err(err.Error())
-			close(outputs)
-			return
+			return nil, err
 		}
-		if len(elem.([]interface{})) == 0 {
-			a.logger.Err("input is invalid")
-			close(outputs)
-			return
+		for _, v := range vv {
+			violations = append(violations, InputViolation{Index: i, Violation: v})
+		}
+		if len(vv) == 0 {
+			valid = append(valid, e)
+			continue
+		}
+		if eventCfg.Sync || eventCfg.Stream {
+			inputViolations().Add(eventCfg.OutputChannel, 1)
+		} else {
+			a.rejectInput(eventCfg, i, vv)
 		}
 	}
-	if eventCfg.SingleOutputEvent {
-		g.Go(func() error {
-			ev, err := a.executeTemplate(tmpl, elem)
-			if err != nil {
-				return err
-			}
-			channel, err := a.executeTemplate(channelTmpl, elem)
-			if err != nil {
-				a.logger.Err(err.Error())
+	if len(violations) == 0 {
+		return elem, nil
+	}
+	if eventCfg.Sync || eventCfg.Stream {
+		return nil, inputViolationsError(violations)
+	}
+	if len(valid) == 0 {
+		return nil, nil
+	}
+	if !isList {
+		return valid[0], nil
+	}
+	return valid, nil
+}
+
+// rejectInput logs and counts an input element dropped because it does not match the
+// input schema.
+func (a *Adapter) rejectInput(eventCfg *EventConfiguration, index int, violations []schema.Violation) {
+	inputViolations().Add(eventCfg.OutputChannel, 1)
+	a.logger.Err("input element %d rejected (output channel %s): %s", index, eventCfg.OutputChannel, schema.Error(violations).Error())
+}
+
+// checkOutput validates the rendered output against the output schema, and encodes
+// it if the event has an output encoding. The body of the output is left unchanged
+// if it is rejected.
+func (a *Adapter) checkOutput(outputSchema *schema.Schema, encoder format.Encoder, o *output) error {
+	if outputSchema != nil {
+		violations, err := outputSchema.ValidateJSON(o.body)
+		if err != nil {
+			return err
+		}
+		if len(violations) > 0 {
+			return schema.Error(violations)
+		}
+	}
+	if encoder != nil {
+		body, err := encoder.Encode(o.body)
+		if err != nil {
+			return fmt.Errorf("outputEncoding: %s", err.Error())
+		}
+		o.body = body
+	}
+	return nil
+}
+
+// inputFromRequest returns the input document of the request, a map if the event
+// expects a single input event, a list otherwise. A SkippedError is returned along
+// with the document if the formatter skipped invalid parts of the body.
+func (a *Adapter) inputFromRequest(req *router.Request, eventCfg *EventConfiguration, formatter format.Formatter) (interface{}, error) {
+	if req.Method == http.MethodGet {
+		d := format.FormatValues(req.Query)
+		if eventCfg.SingleInputEvent {
+			return d, nil
+		}
+		return []interface{}{d}, nil
+	}
+	formatter, err := a.requestFormatter(req, eventCfg, formatter)
+	if err != nil {
+		return nil, err
+	}
+	if eventCfg.SingleInputEvent {
+		return formatter.FormatSingle(req.Body)
+	}
+	elems, err := formatter.FormatMultiple(req.Body)
+	var skipped *format.SkippedError
+	if err != nil && !errors.As(err, &skipped) {
+		return nil, err
+	}
+	if len(elems) == 0 {
+		if skipped != nil {
+			return nil, skipped
+		}
+		return nil, errInputInvalid
+	}
+	// skipped parts are reported along with the elements
+	return elems, err
+}
+
+// formatConfig returns the configuration of the formatters of the event, the
+// inputOptions of the event override the global options of each format.
+func (a *Adapter) formatConfig(eventCfg *EventConfiguration) (configuration.Provider, error) {
+	return a.layeredConfig(eventCfg.InputOptions)
+}
+
+// getEncoder returns the encoder of the outputs of the event, the
+// outputEncodingOptions of the event override the global options of the encoding.
+func (a *Adapter) getEncoder(eventCfg *EventConfiguration) (format.Encoder, error) {
+	cfg, err := a.layeredConfig(map[string]map[string]interface{}{eventCfg.OutputEncoding: eventCfg.OutputEncodingOptions})
+	if err != nil {
+		return nil, err
+	}
+	return format.GetEncoder(&format.FormatterConfiguration{Logger: a.logger, Config: cfg}, eventCfg.OutputEncoding)
+}
+
+// layeredConfig returns a copy of the configuration whose options, by format name,
+// are replaced by the given ones.
+func (a *Adapter) layeredConfig(options map[string]map[string]interface{}) (configuration.Provider, error) {
+	if len(options) == 0 {
+		return a.config, nil
+	}
+	cfg, err := configuration.NewKoanfProvider(configuration.ProviderConfig{Logger: a.logger, Source: a.config})
+	if err != nil {
+		return nil, err
+	}
+	for name, options := range options {
+		for key, value := range options {
+			cfg.Set(name+"."+key, value)
+		}
+	}
+	return cfg, nil
+}
+
+// requestFormatter returns the formatter of the event, or the one matching the
+// Content-Type of the request for inputFormat auto, set up with the Content-Type
+// parameters it needs.
+func (a *Adapter) requestFormatter(req *router.Request, eventCfg *EventConfiguration, formatter format.Formatter) (format.Formatter, error) {
+	if formatter == nil {
+		name, err := format.GetFormatterName(req.ContentType)
+		if err != nil {
+			return nil, err
+		}
+		if formatter, err = a.formatterByName(name, eventCfg.formatConfig); err != nil {
+			return nil, err
+		}
+	}
+	if f, isContentType := formatter.(format.ContentTypeFormatter); isContentType {
+		return f.WithContentType(req.ContentType)
+	}
+	return formatter, nil
+}
+
+// streamElements returns an iterator over the input elements read from the body of
+// the request, checked against the input schema and transformed. Formatters which
+// cannot stream read the whole body first. Invalid elements are skipped, the
+// iterator reporting their violations once the body has been read, as the valid
+// elements have already been rendered.
+func (a *Adapter) streamElements(req *router.Request, eventCfg *EventConfiguration, formatter format.Formatter, inputSchema *schema.Schema, transformer *transform.Transformer) (func(func(interface{}) error) error, error) {
+	formatter, err := a.requestFormatter(req, eventCfg, formatter)
+	if err != nil {
+		return nil, err
+	}
+	read := func(fn func(interface{}) error) error {
+		if sf, isStream := formatter.(format.StreamFormatter); isStream {
+			return sf.FormatStream(req.Reader, fn)
+		}
+		body, err := io.ReadAll(req.Reader)
+		if err != nil {
+			return err
+		}
+		elems, err := formatter.FormatMultiple(body)
+		var skipped *format.SkippedError
+		if err != nil && !errors.As(err, &skipped) {
+			return err
+		}
+		for _, elem := range elems {
+			if err := fn(elem); err != nil {
 				return err
 			}
-			outputs <- output{channel: string(channel), body: ev}
-			return nil
-		})
-	} else {
-		if !eventCfg.SingleInputEvent {
-			elems := elem.([]interface{})
-			var elemsPerBatch int64
-			for i := 0; i < len(elems); i++ {
-				if eventCfg.BatchSize > 0 {
-					if eventCfg.BatchSize == elemsPerBatch {
-						elemsPerBatch = 0
-						time.Sleep(eventCfg.batchInterval)
-					}
+		}
+		return err
+	}
+	return func(fn func(interface{}) error) error {
+		index := 0
+		var violations []InputViolation
+		err := read(func(elem interface{}) error {
+			i := index
+			index++
+			if inputSchema != nil {
+				vv, err := inputSchema.Validate(elem)
+				if err != nil {
+					return err
 				}
-				i := i
-				g.Go(func() error {
-					i = i
-					ev, err := a.executeTemplate(tmpl, elems[i])
-					if err != nil {
-						return err
-					}
-					channel, err := a.executeTemplate(channelTmpl, elems[i])
-					if err != nil {
-						return err
+				if len(vv) > 0 {
+					inputViolations().Add(eventCfg.OutputChannel, 1)
+					for _, v := range vv {
+						violations = append(violations, InputViolation{Index: i, Violation: v})
 					}
-					outputs <- output{channel: string(channel), body: ev}
 					return nil
-				})
-				if eventCfg.BatchSize > 0 {
-					elemsPerBatch += 1
 				}
 			}
-		} else if eventCfg.ChrootPath != "" {
-			a.logger.Err("%v: output type invalid: cannot have multiple output type for a single input type if chrootPath is empty", eventCfg)
-			close(outputs)
-			return
-		} else {
-			// implement possibility to chroot and iterate through this chrooted key
+			elems := []interface{}{elem}
+			if transformer != nil {
+				if elems, err = transformer.Transform(elem); err != nil {
+					return err
+				}
+			}
+			for _, e := range elems {
+				if err := fn(e); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		var skipped *format.SkippedError
+		if err != nil && !errors.As(err, &skipped) {
+			return &router.Error{Status: http.StatusBadRequest, Err: err}
+		}
+		if len(violations) > 0 {
+			return inputViolationsError(violations)
 		}
+		// skipped parts of the body are reported once the elements have been rendered
+		return err
+	}, nil
+}
+
+// transformInput runs the transform on each input element, it returns nil if all
+// elements have been dropped.
+func transformInput(elem interface{}, eventCfg *EventConfiguration, transformer *transform.Transformer) (interface{}, error) {
+	if eventCfg.SingleInputEvent {
+		elems, err := transformer.Transform(elem)
+		if err != nil {
+			return nil, err
+		}
+		switch len(elems) {
+		case 0:
+			return nil, nil
+		case 1:
+			return elems[0], nil
+		}
+		return nil, errors.New("transform: a single input event cannot be split")
 	}
-	if err := g.Wait(); err != nil {
-		a.logger.Err(err.Error())
-		close(outputs)
-		return
+	var transformed []interface{}
+	for _, e := range elem.([]interface{}) {
+		elems, err := transformer.Transform(e)
+		if err != nil {
+			return nil, err
+		}
+		transformed = append(transformed, elems...)
 	}
+	if len(transformed) == 0 {
+		return nil, nil
+	}
+	return transformed, nil
 }
 
-func (a *Adapter) getOutputTemplate(eventCfg *EventConfiguration) (*gotemplate.Template, error) {
-	tmpl := gotemplate.New(filepath.Base(eventCfg.OutputTemplate))
-	funcs := template.GetDefaultFuncs()
-	if len(eventCfg.ExtendedFunctions) > 0 {
-		for pluginFile, functions := range eventCfg.ExtendedFunctions {
-			p, err := plugin.Open(pluginFile)
-			if err != nil {
-				return nil, err
-			}
-			for _, function := range functions {
-				s, err := p.Lookup(function)
-				if err != nil {
-					return nil, err
-				}
-				f, ok := s.(func(...interface{}) (interface{}, error))
-				if !ok {
-					return nil, fmt.Errorf("extended function %s has an invalid signature (required signature is func(...interface{}) (interface{}, error))", function)
+// outputsFromEvent renders the outputs of the input document.
+func (a *Adapter) outputsFromEvent(elem interface{}, eventCfg *EventConfiguration, tmpl *gotemplate.Template, channelTmpl *gotemplate.Template, outputs chan (output)) error {
+	if eventCfg.SingleOutputEvent {
+		return a.outputFromElement(elem, tmpl, channelTmpl, eventCfg, outputs)
+	}
+	if !eventCfg.SingleInputEvent {
+		elems := elem.([]interface{})
+		return a.outputsFromElements(func(fn func(interface{}) error) error {
+			for _, e := range elems {
+				if err := fn(e); err != nil {
+					return err
 				}
-				funcs[function] = f
 			}
+			return nil
+		}, eventCfg, tmpl, channelTmpl, outputs)
+	}
+	if eventCfg.ChrootPath != "" {
+		return fmt.Errorf("%v: output type invalid: cannot have multiple output type for a single input type if chrootPath is empty", eventCfg)
+	}
+	// implement possibility to chroot and iterate through this chrooted key
+	return nil
+}
+
+// outputsFromElements renders the outputs of the elements given by each, at most
+// maxConcurrentOutputs at a time so that streamed inputs are rendered as they are read.
+func (a *Adapter) outputsFromElements(each func(func(interface{}) error) error, eventCfg *EventConfiguration, tmpl *gotemplate.Template, channelTmpl *gotemplate.Template, outputs chan (output)) error {
+	g := errgroup.Group{}
+	g.SetLimit(maxConcurrentOutputs)
+	var elemsPerBatch int64
+	err := each(func(elem interface{}) error {
+		if eventCfg.BatchSize > 0 {
+			if eventCfg.BatchSize == elemsPerBatch {
+				elemsPerBatch = 0
+				time.Sleep(eventCfg.batchInterval)
+			}
+			elemsPerBatch += 1
+		}
+		g.Go(func() error {
+			return a.outputFromElement(elem, tmpl, channelTmpl, eventCfg, outputs)
+		})
+		return nil
+	})
+	if werr := g.Wait(); werr != nil {
+		// rendering errors prevail over the parts of the input which were skipped
+		var skipped *format.SkippedError
+		if err == nil || errors.As(err, &skipped) {
+			err = werr
 		}
 	}
-	tmpl, err := tmpl.Funcs(funcs).ParseFiles(eventCfg.OutputTemplate)
+	return err
+}
+
+func (a *Adapter) outputFromElement(elem interface{}, tmpl *gotemplate.Template, channelTmpl *gotemplate.Template, eventCfg *EventConfiguration, outputs chan (output)) error {
+	ev, err := a.renderOutput(eventCfg, tmpl, elem)
+	if err != nil {
+		return err
+	}
+	channel, err := a.executeTemplate(channelTmpl, elem)
+	if err != nil {
+		return err
+	}
+	outputs <- output{channel: string(channel), body: ev}
+	return nil
+}
+
+func (a *Adapter) getOutputTemplate(eventCfg *EventConfiguration, funcs gotemplate.FuncMap) (*gotemplate.Template, error) {
+	if eventCfg.OutputFormat != OutputFormatJSON {
+		return gotemplate.New(filepath.Base(eventCfg.OutputTemplate)).Funcs(funcs).ParseFiles(eventCfg.OutputTemplate)
+	}
+	skeleton, err := os.ReadFile(eventCfg.OutputTemplate)
 	if err != nil {
 		return nil, err
 	}
+	tmpl, err := template.ParseJSONSkeleton(filepath.Base(eventCfg.OutputTemplate), skeleton, funcs)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", eventCfg.OutputTemplate, err.Error())
+	}
 	return tmpl, nil
 }
 
-func (a *Adapter) getChannelTemplate(eventCfg *EventConfiguration) (*gotemplate.Template, error) {
-	tmpl := gotemplate.New("channel")
+func (a *Adapter) getChannelTemplate(eventCfg *EventConfiguration, funcs gotemplate.FuncMap) (*gotemplate.Template, error) {
+	tmpl, err := gotemplate.New("channel").Funcs(funcs).Parse(eventCfg.OutputChannel)
+	if err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// getFuncs returns the functions available in the templates of the event.
+func (a *Adapter) getFuncs(eventCfg *EventConfiguration) (gotemplate.FuncMap, error) {
 	funcs := template.GetDefaultFuncs()
-	if len(eventCfg.ExtendedFunctions) > 0 {
-		for pluginFile, functions := range eventCfg.ExtendedFunctions {
-			p, err := plugin.Open(pluginFile)
-			if err != nil {
-				return nil, err
-			}
-			for _, function := range functions {
-				s, err := p.Lookup(function)
-				if err != nil {
-					return nil, err
-				}
-				f, ok := s.(func(...interface{}) (interface{}, error))
-				if !ok {
-					return nil, fmt.Errorf("extended function %s has an invalid signature (required signature is func(...interface{}) (interface{}, error))", function)
-				}
-				funcs[function] = f
+	funcs["Principal"] = func() *auth.Principal { return &auth.Principal{} }
+	for pluginFile, functions := range eventCfg.ExtendedFunctions {
+		if err := loadPluginFuncs(funcs, pluginFile, functions); err != nil {
+			return nil, err
+		}
+	}
+	for command, functions := range eventCfg.ExternalFunctions {
+		p, err := extension.Get(a.logger, command)
+		if err != nil {
+			return nil, err
+		}
+		provided, err := p.Functions()
+		if err != nil {
+			return nil, err
+		}
+		for _, function := range functions {
+			if provided != nil && !contains(provided, function) {
+				return nil, fmt.Errorf("extension %s does not provide function %s", command, function)
 			}
+			funcs[function] = p.Func(function)
 		}
 	}
-	tmpl, err := tmpl.Funcs(funcs).Parse(eventCfg.OutputChannel)
+	return funcs, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// withPrincipal returns a copy of the template whose Principal function returns the
+// given principal.
+func withPrincipal(tmpl *gotemplate.Template, principal *auth.Principal) (*gotemplate.Template, error) {
+	t, err := tmpl.Clone()
 	if err != nil {
 		return nil, err
 	}
-	return tmpl, nil
+	t = t.Funcs(gotemplate.FuncMap{"Principal": func() *auth.Principal { return principal }})
+	return template.BindJSONSkeleton(t), nil
+}
+
+// renderOutput executes the output template, encoding the result when the event
+// renders JSON.
+func (a *Adapter) renderOutput(eventCfg *EventConfiguration, tmpl *gotemplate.Template, data interface{}) ([]byte, error) {
+	out, err := a.executeTemplate(tmpl, data)
+	if err != nil || eventCfg.OutputFormat != OutputFormatJSON {
+		return out, err
+	}
+	return template.FinishJSON(out)
 }
 
 func (a *Adapter) executeTemplate(tmpl *gotemplate.Template, data interface{}) ([]byte, error) {
@@ -255,6 +720,163 @@ func (a *Adapter) executeTemplate(tmpl *gotemplate.Template, data interface{}) (
 	return body.Bytes(), nil
 }
 
-func (a *EventConfiguration) ensureConfiguration() error {
-	return nil
+// Validate checks the event configuration, returning all the problems found.
+func (a *Adapter) Validate(eventCfg *EventConfiguration) []error {
+	errs := eventCfg.ensureConfiguration()
+	formatCfg, err := a.formatConfig(eventCfg)
+	if err != nil {
+		return append(errs, &FieldError{Field: "inputOptions", Err: err})
+	}
+	if eventCfg.InputFormat != "" && eventCfg.InputFormat != format.Auto {
+		if _, err := a.formatterByName(eventCfg.InputFormat, formatCfg); err != nil {
+			errs = append(errs, &FieldError{Field: "inputFormat", Err: err})
+		}
+	}
+	for name := range eventCfg.InputOptions {
+		if name == eventCfg.InputFormat {
+			// checked with the formatter of the event
+			continue
+		}
+		if eventCfg.InputFormat != format.Auto {
+			errs = append(errs, &FieldError{Field: "inputOptions." + name, Err: fmt.Errorf("options of %s do not apply to inputFormat %s", name, eventCfg.InputFormat)})
+		} else if _, err := a.formatterByName(name, formatCfg); err != nil {
+			errs = append(errs, &FieldError{Field: "inputOptions." + name, Err: err})
+		}
+	}
+	if eventCfg.Transform != nil {
+		if _, err := transform.NewTransformer(eventCfg.Transform); err != nil {
+			errs = append(errs, &FieldError{Field: "transform", Err: err})
+		}
+	}
+	if eventCfg.OutputWriter != "" && !writer.Exists(eventCfg.OutputWriter) {
+		errs = append(errs, &FieldError{Field: "outputWriter", Err: fmt.Errorf("unknown writer name %s", eventCfg.OutputWriter)})
+	}
+	if eventCfg.InputSchema != "" {
+		if _, err := schema.Load(eventCfg.InputSchema); err != nil {
+			errs = append(errs, &FieldError{Field: "inputSchema", Err: err})
+		}
+	}
+	if eventCfg.OutputSchema != "" {
+		if _, err := schema.Load(eventCfg.OutputSchema); err != nil {
+			errs = append(errs, &FieldError{Field: "outputSchema", Err: err})
+		}
+	}
+	if eventCfg.OutputEncoding != "" {
+		if _, err := a.getEncoder(eventCfg); err != nil {
+			errs = append(errs, &FieldError{Field: "outputEncoding", Err: err})
+		}
+	}
+	if eventCfg.DeadLetterWriter != "" && !writer.Exists(eventCfg.DeadLetterWriter) {
+		errs = append(errs, &FieldError{Field: "deadLetterWriter", Err: fmt.Errorf("unknown writer name %s", eventCfg.DeadLetterWriter)})
+	}
+	funcs, err := a.getFuncs(eventCfg)
+	if err != nil {
+		field := "extendedFunctions"
+		if len(eventCfg.ExternalFunctions) > 0 {
+			field = "externalFunctions"
+		}
+		// templates cannot be checked without their functions
+		return append(errs, &FieldError{Field: field, Err: err})
+	}
+	if eventCfg.OutputTemplate != "" {
+		if _, err := a.getOutputTemplate(eventCfg, funcs); err != nil {
+			errs = append(errs, &FieldError{Field: "outputTemplate", Err: err})
+		}
+	}
+	if eventCfg.OutputChannel != "" {
+		if _, err := a.getChannelTemplate(eventCfg, funcs); err != nil {
+			errs = append(errs, &FieldError{Field: "outputChannel", Err: err})
+		}
+	}
+	return errs
+}
+
+// FieldError reports a problem on a field of an event configuration.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (a *EventConfiguration) ensureConfiguration() []error {
+	var errs []error
+	fail := func(field, format string, v ...interface{}) {
+		errs = append(errs, &FieldError{Field: field, Err: fmt.Errorf(format, v...)})
+	}
+	for _, m := range a.Methods {
+		switch strings.ToUpper(m) {
+		case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			fail("methods", "unsupported method %s", m)
+		}
+	}
+	if a.InputFormat == "" {
+		fail("inputFormat", "is required")
+	}
+	if a.OutputTemplate == "" {
+		fail("outputTemplate", "is required")
+	}
+	switch a.OutputFormat {
+	case "", OutputFormatText, OutputFormatJSON:
+	default:
+		fail("outputFormat", "unsupported output format %s", a.OutputFormat)
+	}
+	if a.OutputWriter == "" {
+		fail("outputWriter", "is required")
+	}
+	if a.OutputChannel == "" {
+		fail("outputChannel", "is required")
+	}
+	if a.SingleInputEvent && !a.SingleOutputEvent {
+		fail("singleOutputEvent", "a single input event requires singleOutputEvent (chrootPath is not implemented)")
+	}
+	if a.ChrootPath != "" {
+		fail("chrootPath", "is not implemented")
+	}
+	if a.DeadLetterWriter != "" && a.OutputSchema == "" && a.OutputEncoding == "" {
+		fail("deadLetterWriter", "requires outputSchema or outputEncoding")
+	}
+	if len(a.OutputEncodingOptions) > 0 && a.OutputEncoding == "" {
+		fail("outputEncodingOptions", "requires outputEncoding")
+	}
+	if a.DeadLetterWriter != "" && a.DeadLetterChannel == "" {
+		fail("deadLetterChannel", "is required with deadLetterWriter")
+	}
+	if a.DeadLetterChannel != "" && a.DeadLetterWriter == "" {
+		fail("deadLetterChannel", "requires deadLetterWriter")
+	}
+	if a.Stream && (a.SingleInputEvent || a.SingleOutputEvent) {
+		fail("stream", "cannot be used with singleInputEvent or singleOutputEvent")
+	}
+	if a.Stream && a.Auth != nil && a.Auth.HMAC != nil {
+		fail("stream", "cannot be used with hmac authentication, which needs the whole body")
+	}
+	if a.BatchSize < 0 {
+		fail("batchSize", "cannot be negative")
+	}
+	if a.BatchSize > 0 && a.SingleOutputEvent {
+		fail("batchSize", "cannot be used with singleOutputEvent")
+	}
+	if a.BatchInterval != "" {
+		if a.BatchSize == 0 {
+			fail("batchInterval", "requires batchSize")
+		}
+		if d, err := time.ParseDuration(a.BatchInterval); err != nil {
+			fail("batchInterval", "%s", err.Error())
+		} else if d < 0 {
+			fail("batchInterval", "cannot be negative")
+		}
+	}
+	if a.Auth != nil {
+		if _, err := auth.NewAuthenticator(a.Auth); err != nil {
+			fail("auth", "%s", err.Error())
+		}
+	}
+	if _, err := configuration.ParseSize(a.MaxBodySize); err != nil {
+		fail("maxBodySize", "%s", err.Error())
+	}
+	return errs
 }
@@ -0,0 +1,29 @@
+//go:build cgo && (linux || darwin || freebsd)
+
+package adapter
+
+import (
+	"fmt"
+	"plugin"
+	gotemplate "text/template"
+)
+
+// loadPluginFuncs adds the given functions of a go plugin to funcs.
+func loadPluginFuncs(funcs gotemplate.FuncMap, pluginFile string, functions []string) error {
+	p, err := plugin.Open(pluginFile)
+	if err != nil {
+		return err
+	}
+	for _, function := range functions {
+		s, err := p.Lookup(function)
+		if err != nil {
+			return err
+		}
+		f, ok := s.(func(...interface{}) (interface{}, error))
+		if !ok {
+			return fmt.Errorf("extended function %s has an invalid signature (required signature is func(...interface{}) (interface{}, error))", function)
+		}
+		funcs[function] = f
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !cgo || !(linux || darwin || freebsd)
+
+package adapter
+
+import (
+	"errors"
+	gotemplate "text/template"
+)
+
+// loadPluginFuncs fails, go plugins are not supported by this build, externalFunctions
+// must be used instead.
+func loadPluginFuncs(funcs gotemplate.FuncMap, pluginFile string, functions []string) error {
+	return errors.New("go plugins are not supported by this build, use externalFunctions instead")
+}
@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+)
+
+// Configuration describes how requests are authenticated. HMAC signature, when
+// configured, is always verified, then the request must be accepted by at least one
+// of the other configured methods.
+type Configuration struct {
+	HMAC       *HMACConfiguration       `config:"hmac"`
+	Bearer     *BearerConfiguration     `config:"bearer"`
+	APIKey     *APIKeyConfiguration     `config:"apiKey"`
+	Basic      *BasicConfiguration      `config:"basic"`
+	JWT        *JWTConfiguration        `config:"jwt"`
+	ClientCert *ClientCertConfiguration `config:"clientCert"`
+}
+
+// Principal describes the authenticated caller, it is exposed to templates. Method is
+// the method which identified the caller, Methods lists every method which accepted
+// the request, the HMAC signature first.
+type Principal struct {
+	Method  string
+	Methods []string
+	Name    string
+	Claims  map[string]interface{}
+}
+
+// Authenticator checks that a request, whose body has already been read, is
+// authorized and returns the authenticated principal.
+type Authenticator interface {
+	Authenticate(req *http.Request, body []byte) (*Principal, error)
+}
+
+// Challenger is implemented by authenticators telling clients how to authenticate,
+// its challenges are sent in WWW-Authenticate headers of 401 responses.
+type Challenger interface {
+	Challenges() []string
+}
+
+type authenticator struct {
+	hmac       Authenticator
+	identities []Authenticator
+}
+
+// NewAuthenticator returns the authenticator described by the configuration, or nil
+// if no authentication is configured.
+func NewAuthenticator(cfg *Configuration) (Authenticator, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	a := &authenticator{}
+	var err error
+	if cfg.HMAC != nil {
+		if a.hmac, err = NewHMACAuthenticator(cfg.HMAC); err != nil {
+			return nil, err
+		}
+	}
+	identities := []struct {
+		configured bool
+		new        func() (Authenticator, error)
+	}{
+		{cfg.ClientCert != nil, func() (Authenticator, error) { return NewClientCertAuthenticator(cfg.ClientCert) }},
+		{cfg.Bearer != nil, func() (Authenticator, error) { return NewBearerAuthenticator(cfg.Bearer) }},
+		{cfg.APIKey != nil, func() (Authenticator, error) { return NewAPIKeyAuthenticator(cfg.APIKey) }},
+		{cfg.JWT != nil, func() (Authenticator, error) { return NewJWTAuthenticator(cfg.JWT) }},
+		{cfg.Basic != nil, func() (Authenticator, error) { return NewBasicAuthenticator(cfg.Basic) }},
+	}
+	for _, identity := range identities {
+		if !identity.configured {
+			continue
+		}
+		i, err := identity.new()
+		if err != nil {
+			return nil, err
+		}
+		a.identities = append(a.identities, i)
+	}
+	if a.hmac == nil && len(a.identities) == 0 {
+		return nil, nil
+	}
+	return a, nil
+}
+
+func (a *authenticator) Authenticate(req *http.Request, body []byte) (*Principal, error) {
+	var principal *Principal
+	if a.hmac != nil {
+		p, err := a.hmac.Authenticate(req, body)
+		if err != nil {
+			return nil, err
+		}
+		principal = p
+	}
+	if len(a.identities) == 0 {
+		return principal, nil
+	}
+	errs := make([]string, 0, len(a.identities))
+	for _, identity := range a.identities {
+		p, err := identity.Authenticate(req, body)
+		if err == nil {
+			if principal != nil {
+				p.Methods = append(principal.Methods, p.Method)
+			}
+			return p, nil
+		}
+		errs = append(errs, strings.TrimPrefix(err.Error(), ErrUnauthorized.Error()+": "))
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnauthorized, strings.Join(errs, ", "))
+}
+
+func (a *authenticator) Challenges() []string {
+	var challenges []string
+	for _, identity := range a.identities {
+		c, ok := identity.(Challenger)
+		if !ok {
+			continue
+		}
+		for _, challenge := range c.Challenges() {
+			if !contains(challenges, challenge) {
+				challenges = append(challenges, challenge)
+			}
+		}
+	}
+	return challenges
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSecret returns the name associated to the given secret, comparing secrets in
+// constant time.
+func matchSecret(secrets map[string]string, secret string) (string, bool) {
+	var name string
+	var found bool
+	for n, s := range secrets {
+		if subtle.ConstantTimeCompare([]byte(s), []byte(secret)) == 1 {
+			name, found = n, true
+		}
+	}
+	return name, found
+}
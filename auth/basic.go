@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultBasicRealm = "http-event-adapter"
+)
+
+// BasicConfiguration accepts basic auth credentials checked against an htpasswd
+// file, bcrypt and {SHA} hashes are supported. Realm is sent to clients in the
+// WWW-Authenticate challenge.
+type BasicConfiguration struct {
+	Htpasswd string `config:"htpasswd"`
+	Realm    string `config:"realm"`
+}
+
+type basicAuthenticator struct {
+	realm  string
+	hashes map[string]string
+}
+
+func NewBasicAuthenticator(cfg *BasicConfiguration) (Authenticator, error) {
+	f, err := os.Open(cfg.Htpasswd)
+	if err != nil {
+		return nil, fmt.Errorf("basic: %s", err.Error())
+	}
+	defer f.Close()
+	a := &basicAuthenticator{realm: cfg.Realm, hashes: make(map[string]string)}
+	if a.realm == "" {
+		a.realm = defaultBasicRealm
+	}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		user, hash, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("basic: %s:%d: malformed entry", cfg.Htpasswd, line)
+		}
+		if !strings.HasPrefix(hash, "$2") && !strings.HasPrefix(hash, "{SHA}") {
+			return nil, fmt.Errorf("basic: %s:%d: unsupported hash for user %s (bcrypt or {SHA} required)", cfg.Htpasswd, line, user)
+		}
+		a.hashes[user] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("basic: %s", err.Error())
+	}
+	return a, nil
+}
+
+func (a *basicAuthenticator) Authenticate(req *http.Request, body []byte) (*Principal, error) {
+	user, password, ok := req.BasicAuth()
+	if !ok {
+		return nil, fmt.Errorf("%w: missing basic credentials", ErrUnauthorized)
+	}
+	hash, exists := a.hashes[user]
+	if !exists || !checkPassword(hash, password) {
+		return nil, fmt.Errorf("%w: invalid basic credentials", ErrUnauthorized)
+	}
+	return &Principal{Method: "basic", Methods: []string{"basic"}, Name: user}, nil
+}
+
+func (a *basicAuthenticator) Challenges() []string {
+	return []string{`Basic realm=` + quote(a.realm) + `, charset="UTF-8"`}
+}
+
+// quote returns the value as a quoted string of an http header.
+func quote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+func checkPassword(hash, password string) bool {
+	if strings.HasPrefix(hash, "{SHA}") {
+		sum := sha1.Sum([]byte(password))
+		expected := base64.StdEncoding.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(hash, "{SHA}")), []byte(expected)) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
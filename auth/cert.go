@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// ClientCertConfiguration accepts requests presenting a TLS client certificate
+// signed by the given CA, optionally restricted to some common names.
+type ClientCertConfiguration struct {
+	CA          string   `config:"ca"`
+	CommonNames []string `config:"commonNames"`
+}
+
+type clientCertAuthenticator struct {
+	roots       *x509.CertPool
+	commonNames map[string]bool
+}
+
+func NewClientCertAuthenticator(cfg *ClientCertConfiguration) (Authenticator, error) {
+	a := &clientCertAuthenticator{commonNames: make(map[string]bool)}
+	if cfg.CA != "" {
+		pem, err := os.ReadFile(cfg.CA)
+		if err != nil {
+			return nil, fmt.Errorf("clientCert: %s", err.Error())
+		}
+		a.roots = x509.NewCertPool()
+		if !a.roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("clientCert: no certificate found in %s", cfg.CA)
+		}
+	}
+	for _, cn := range cfg.CommonNames {
+		a.commonNames[cn] = true
+	}
+	return a, nil
+}
+
+func (a *clientCertAuthenticator) Authenticate(req *http.Request, body []byte) (*Principal, error) {
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("%w: missing client certificate", ErrUnauthorized)
+	}
+	cert := req.TLS.PeerCertificates[0]
+	if a.roots != nil {
+		intermediates := x509.NewCertPool()
+		for _, c := range req.TLS.PeerCertificates[1:] {
+			intermediates.AddCert(c)
+		}
+		if _, err := cert.Verify(x509.VerifyOptions{
+			Roots:         a.roots,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrUnauthorized, err.Error())
+		}
+	} else if len(req.TLS.VerifiedChains) == 0 {
+		return nil, fmt.Errorf("%w: client certificate has not been verified", ErrUnauthorized)
+	}
+	if len(a.commonNames) > 0 && !a.commonNames[cert.Subject.CommonName] {
+		return nil, fmt.Errorf("%w: common name %s is not allowed", ErrUnauthorized, cert.Subject.CommonName)
+	}
+	return &Principal{Method: "clientCert", Methods: []string{"clientCert"}, Name: cert.Subject.CommonName}, nil
+}
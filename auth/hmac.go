@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultHMACAlgorithm = "sha256"
+	defaultHMACEncoding  = "hex"
+	defaultHMACTolerance = 5 * time.Minute
+	defaultHMACPayload   = "{body}"
+)
+
+type HMACConfiguration struct {
+	Algorithm string `config:"algorithm"`
+	Header    string `config:"header"`
+	Prefix    string `config:"prefix"`
+	Encoding  string `config:"encoding"`
+	// Secret is usually a secret reference (env:NAME or file:///path) resolved when
+	// the configuration is loaded.
+	Secret string `config:"secret"`
+	// TimestampHeader enables replay protection, requests whose timestamp (unix
+	// seconds or RFC3339) is further than Tolerance from now are rejected.
+	TimestampHeader string `config:"timestampHeader"`
+	Tolerance       string `config:"tolerance"`
+	// SignatureKey reads the header as comma separated key=value pairs (eg: Stripe's
+	// "t=1492774577,v1=5257a8..."), the signatures being the values of this key.
+	SignatureKey string `config:"signatureKey"`
+	// TimestampKey reads the timestamp from this key of the header pairs instead of
+	// a TimestampHeader, it requires SignatureKey.
+	TimestampKey string `config:"timestampKey"`
+	// Payload describes the signed content, {body} and {timestamp} being replaced by
+	// the raw request body and the timestamp header value (eg: "{timestamp}.{body}").
+	Payload string `config:"payload"`
+}
+
+type hmacAuthenticator struct {
+	hash            func() hash.Hash
+	header          string
+	prefix          string
+	decode          func(string) ([]byte, error)
+	secret          []byte
+	timestampHeader string
+	tolerance       time.Duration
+	signatureKey    string
+	timestampKey    string
+	payload         string
+}
+
+func NewHMACAuthenticator(cfg *HMACConfiguration) (Authenticator, error) {
+	if cfg.Header == "" {
+		return nil, fmt.Errorf("hmac: header is required")
+	}
+	a := &hmacAuthenticator{
+		header:          cfg.Header,
+		prefix:          cfg.Prefix,
+		timestampHeader: cfg.TimestampHeader,
+		signatureKey:    cfg.SignatureKey,
+		timestampKey:    cfg.TimestampKey,
+		tolerance:       defaultHMACTolerance,
+		payload:         defaultHMACPayload,
+	}
+	algorithm := cfg.Algorithm
+	if algorithm == "" {
+		algorithm = defaultHMACAlgorithm
+	}
+	switch strings.ToLower(algorithm) {
+	case "sha1":
+		a.hash = sha1.New
+	case "sha256":
+		a.hash = sha256.New
+	case "sha512":
+		a.hash = sha512.New
+	default:
+		return nil, fmt.Errorf("hmac: unknown algorithm %s", algorithm)
+	}
+	encoding := cfg.Encoding
+	if encoding == "" {
+		encoding = defaultHMACEncoding
+	}
+	switch strings.ToLower(encoding) {
+	case "hex":
+		a.decode = hex.DecodeString
+	case "base64":
+		a.decode = base64.StdEncoding.DecodeString
+	default:
+		return nil, fmt.Errorf("hmac: unknown encoding %s", encoding)
+	}
+	if cfg.Tolerance != "" {
+		d, err := time.ParseDuration(cfg.Tolerance)
+		if err != nil {
+			return nil, fmt.Errorf("hmac: invalid tolerance: %s", err.Error())
+		}
+		a.tolerance = d
+	}
+	if cfg.Payload != "" {
+		a.payload = cfg.Payload
+	}
+	if a.timestampKey != "" && a.signatureKey == "" {
+		return nil, fmt.Errorf("hmac: timestampKey requires signatureKey")
+	}
+	if a.timestampKey != "" && a.timestampHeader != "" {
+		return nil, fmt.Errorf("hmac: timestampKey and timestampHeader cannot be used together")
+	}
+	if strings.Contains(a.payload, "{timestamp}") && a.timestampHeader == "" && a.timestampKey == "" {
+		return nil, fmt.Errorf("hmac: payload references {timestamp} but timestampHeader and timestampKey are empty")
+	}
+	if cfg.Secret == "" {
+		return nil, fmt.Errorf("hmac: secret is required")
+	}
+	a.secret = []byte(cfg.Secret)
+	return a, nil
+}
+
+func (a *hmacAuthenticator) Authenticate(req *http.Request, body []byte) (*Principal, error) {
+	signatures, timestamp := a.readHeader(req)
+	if len(signatures) == 0 {
+		return nil, fmt.Errorf("%w: missing signature", ErrUnauthorized)
+	}
+	if a.timestampHeader != "" || a.timestampKey != "" {
+		t, err := parseTimestamp(timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrUnauthorized, err.Error())
+		}
+		if d := time.Since(t); d > a.tolerance || d < -a.tolerance {
+			return nil, fmt.Errorf("%w: timestamp is out of tolerance", ErrUnauthorized)
+		}
+	}
+	mac := hmac.New(a.hash, a.secret)
+	before, after, hasBody := strings.Cut(a.payload, "{body}")
+	mac.Write([]byte(strings.ReplaceAll(before, "{timestamp}", timestamp)))
+	if hasBody {
+		mac.Write(body)
+		mac.Write([]byte(strings.ReplaceAll(after, "{timestamp}", timestamp)))
+	}
+	sum := mac.Sum(nil)
+	malformed := true
+	// several signatures are sent while a secret is rolled, one of them has to match
+	for _, signature := range signatures {
+		expected, err := a.decode(signature)
+		if err != nil {
+			continue
+		}
+		malformed = false
+		if hmac.Equal(sum, expected) {
+			return &Principal{Method: "hmac", Methods: []string{"hmac"}}, nil
+		}
+	}
+	if malformed {
+		return nil, fmt.Errorf("%w: malformed signature", ErrUnauthorized)
+	}
+	return nil, fmt.Errorf("%w: invalid signature", ErrUnauthorized)
+}
+
+// readHeader returns the signatures (without prefix) and the timestamp of the
+// request.
+func (a *hmacAuthenticator) readHeader(req *http.Request) ([]string, string) {
+	header := req.Header.Get(a.header)
+	var values []string
+	var timestamp string
+	if a.signatureKey == "" {
+		values = []string{header}
+	} else {
+		for _, pair := range strings.Split(header, ",") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			switch {
+			case !ok:
+			case key == a.signatureKey:
+				values = append(values, value)
+			case key == a.timestampKey:
+				timestamp = value
+			}
+		}
+	}
+	if a.timestampHeader != "" {
+		timestamp = req.Header.Get(a.timestampHeader)
+	}
+	var signatures []string
+	for _, v := range values {
+		if v != "" && strings.HasPrefix(v, a.prefix) {
+			signatures = append(signatures, strings.TrimPrefix(v, a.prefix))
+		}
+	}
+	return signatures, timestamp
+}
+
+func parseTimestamp(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("missing timestamp")
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("malformed timestamp")
+	}
+	return t, nil
+}
@@ -0,0 +1,301 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultJWTLeeway = time.Minute
+	minRSAKeyBits    = 2048
+	maxRSAExponent   = 1<<31 - 1
+)
+
+// JWTConfiguration accepts bearer JWTs signed by one of the keys of a local JWKS
+// file. RS*, PS*, ES* and EdDSA algorithms are supported.
+type JWTConfiguration struct {
+	JWKS     string `config:"jwks"`
+	Issuer   string `config:"issuer"`
+	Audience string `config:"audience"`
+	Leeway   string `config:"leeway"`
+}
+
+type jwtAuthenticator struct {
+	keys     map[string]jwtKey
+	issuer   string
+	audience string
+	leeway   time.Duration
+}
+
+// jwtKey is a verification key, restricted to an algorithm if its JWK has one.
+type jwtKey struct {
+	key crypto.PublicKey
+	alg string
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func NewJWTAuthenticator(cfg *JWTConfiguration) (Authenticator, error) {
+	b, err := os.ReadFile(cfg.JWKS)
+	if err != nil {
+		return nil, fmt.Errorf("jwt: %s", err.Error())
+	}
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &jwks); err != nil {
+		return nil, fmt.Errorf("jwt: %s: %s", cfg.JWKS, err.Error())
+	}
+	a := &jwtAuthenticator{
+		keys:     make(map[string]jwtKey),
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+		leeway:   defaultJWTLeeway,
+	}
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			// encryption keys do not verify signatures
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("jwt: %s: key %s: %s", cfg.JWKS, k.Kid, err.Error())
+		}
+		a.keys[k.Kid] = jwtKey{key: key, alg: k.Alg}
+	}
+	if len(a.keys) == 0 {
+		return nil, fmt.Errorf("jwt: no key found in %s", cfg.JWKS)
+	}
+	if cfg.Leeway != "" {
+		if a.leeway, err = time.ParseDuration(cfg.Leeway); err != nil {
+			return nil, fmt.Errorf("jwt: invalid leeway: %s", err.Error())
+		}
+	}
+	return a, nil
+}
+
+func (a *jwtAuthenticator) Authenticate(req *http.Request, body []byte) (*Principal, error) {
+	token, ok := bearerToken(req)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing bearer token", ErrUnauthorized)
+	}
+	claims, err := a.verify(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: jwt: %s", ErrUnauthorized, err.Error())
+	}
+	sub, _ := claims["sub"].(string)
+	return &Principal{Method: "jwt", Methods: []string{"jwt"}, Name: sub, Claims: claims}, nil
+}
+
+func (a *jwtAuthenticator) Challenges() []string {
+	return []string{"Bearer"}
+}
+
+func (a *jwtAuthenticator) verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	key, exists := a.keys[header.Kid]
+	if !exists {
+		if len(a.keys) != 1 || header.Kid != "" {
+			return nil, fmt.Errorf("unknown key %s", header.Kid)
+		}
+		for _, k := range a.keys {
+			key = k
+		}
+	}
+	if key.alg != "" && key.alg != header.Alg {
+		return nil, fmt.Errorf("algorithm %s does not match key", header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed signature")
+	}
+	if err := verifySignature(header.Alg, key.key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(a.leeway)) {
+		return nil, errors.New("token is expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(a.leeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("token is not valid yet")
+	}
+	if a.issuer != "" && claims["iss"] != a.issuer {
+		return nil, errors.New("invalid issuer")
+	}
+	if a.audience != "" && !hasAudience(claims["aud"], a.audience) {
+		return nil, errors.New("invalid audience")
+	}
+	return claims, nil
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	if len(alg) < 5 {
+		return fmt.Errorf("unsupported algorithm %s", alg)
+	}
+	var hash crypto.Hash
+	switch alg[len(alg)-3:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	}
+	var valid bool
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if hash == 0 || (alg[:2] != "RS" && alg[:2] != "PS") {
+			return fmt.Errorf("algorithm %s does not match key", alg)
+		}
+		h := hash.New()
+		h.Write(signed)
+		if alg[:2] == "RS" {
+			valid = rsa.VerifyPKCS1v15(k, hash, h.Sum(nil), signature) == nil
+		} else {
+			valid = rsa.VerifyPSS(k, hash, h.Sum(nil), signature, nil) == nil
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if hash == 0 || alg[:2] != "ES" || len(signature) != 2*size {
+			return fmt.Errorf("algorithm %s does not match key", alg)
+		}
+		h := hash.New()
+		h.Write(signed)
+		r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+		valid = ecdsa.Verify(k, h.Sum(nil), r, s)
+	case ed25519.PublicKey:
+		if alg != "EdDSA" {
+			return fmt.Errorf("algorithm %s does not match key", alg)
+		}
+		valid = ed25519.Verify(k, signed, signature)
+	}
+	if !valid {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n)}
+		if key.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("key size %d is lower than %d bits", key.N.BitLen(), minRSAKeyBits)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > maxRSAExponent || exponent.Bit(0) == 0 {
+			return nil, errors.New("invalid exponent")
+		}
+		key.E = int(exponent.Int64())
+		return key, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return key, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid key size %d", len(x))
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errors.New("malformed token")
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return errors.New("malformed token")
+	}
+	return nil
+}
+
+func hasAudience(aud interface{}, audience string) bool {
+	switch a := aud.(type) {
+	case string:
+		return a == audience
+	case []interface{}:
+		for _, v := range a {
+			if v == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultAPIKeyHeader = "X-API-Key"
+)
+
+// BearerConfiguration accepts static bearer tokens, tokens are indexed by the name
+// of the principal they identify.
+type BearerConfiguration struct {
+	Tokens map[string]string `config:"tokens"`
+}
+
+// APIKeyConfiguration accepts static api keys sent in a header or a query parameter,
+// keys are indexed by the name of the principal they identify.
+type APIKeyConfiguration struct {
+	Header string            `config:"header"`
+	Query  string            `config:"query"`
+	Keys   map[string]string `config:"keys"`
+}
+
+type bearerAuthenticator struct {
+	tokens map[string]string
+}
+
+type apiKeyAuthenticator struct {
+	header string
+	query  string
+	keys   map[string]string
+}
+
+func NewBearerAuthenticator(cfg *BearerConfiguration) (Authenticator, error) {
+	if len(cfg.Tokens) == 0 {
+		return nil, fmt.Errorf("bearer: no token configured")
+	}
+	return &bearerAuthenticator{tokens: cfg.Tokens}, nil
+}
+
+func (a *bearerAuthenticator) Authenticate(req *http.Request, body []byte) (*Principal, error) {
+	token, ok := bearerToken(req)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing bearer token", ErrUnauthorized)
+	}
+	name, ok := matchSecret(a.tokens, token)
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid bearer token", ErrUnauthorized)
+	}
+	return &Principal{Method: "bearer", Methods: []string{"bearer"}, Name: name}, nil
+}
+
+func (a *bearerAuthenticator) Challenges() []string {
+	return []string{"Bearer"}
+}
+
+func NewAPIKeyAuthenticator(cfg *APIKeyConfiguration) (Authenticator, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, fmt.Errorf("apiKey: no key configured")
+	}
+	a := &apiKeyAuthenticator{header: cfg.Header, query: cfg.Query, keys: cfg.Keys}
+	if a.header == "" && a.query == "" {
+		a.header = defaultAPIKeyHeader
+	}
+	return a, nil
+}
+
+func (a *apiKeyAuthenticator) Authenticate(req *http.Request, body []byte) (*Principal, error) {
+	var key string
+	if a.header != "" {
+		key = req.Header.Get(a.header)
+	}
+	if key == "" && a.query != "" {
+		key = req.URL.Query().Get(a.query)
+	}
+	if key == "" {
+		return nil, fmt.Errorf("%w: missing api key", ErrUnauthorized)
+	}
+	name, ok := matchSecret(a.keys, key)
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid api key", ErrUnauthorized)
+	}
+	return &Principal{Method: "apiKey", Methods: []string{"apiKey"}, Name: name}, nil
+}
+
+func bearerToken(req *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
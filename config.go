@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/skilld-labs/http-event-adapter/configuration"
+	"github.com/skilld-labs/http-event-adapter/log"
+)
+
+const (
+	defaultEnvPrefix = "HEA_"
+)
+
+// configOptions holds the configuration sources given on the command line.
+type configOptions struct {
+	file      string
+	directory string
+	envPrefix string
+	overrides overrides
+}
+
+type overrides []string
+
+func (o *overrides) String() string {
+	return strings.Join(*o, ",")
+}
+
+func (o *overrides) Set(value string) error {
+	*o = append(*o, value)
+	return nil
+}
+
+func addConfigFlags(flags *flag.FlagSet) *configOptions {
+	o := &configOptions{}
+	flags.StringVar(&o.file, "config", "config.yaml", "The path for the config file")
+	flags.StringVar(&o.directory, "config-dir", "", "A directory of *.yaml config fragments merged over the config file")
+	flags.StringVar(&o.envPrefix, "env-prefix", defaultEnvPrefix, "The prefix of environment variables overriding the config, empty to disable")
+	flags.Var(&o.overrides, "set", "A key=value config override, can be repeated")
+	return o
+}
+
+func (o *configOptions) load(l log.Logger) (configuration.Provider, error) {
+	return configuration.NewKoanfProvider(configuration.ProviderConfig{
+		Logger:    l,
+		Source:    o.file,
+		Directory: o.directory,
+		EnvPrefix: o.envPrefix,
+		Overrides: o.overrides,
+	})
+}
+
+// configCommand implements the config subcommand, "config dump" prints the effective
+// configuration with its secrets redacted.
+func configCommand(args []string) int {
+	if len(args) == 0 || args[0] != "dump" {
+		fmt.Fprintln(os.Stderr, "usage: http-event-adapter config dump [flags]")
+		return 2
+	}
+	flags := flag.NewFlagSet("config dump", flag.ExitOnError)
+	options := addConfigFlags(flags)
+	flags.Parse(args[1:])
+
+	l := log.NewJsonLogger(&log.LoggerConfiguration{Verbosity: log.Fatal})
+	cfg, err := options.load(l)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	out, err := yaml.Marshal(cfg.Redacted())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	fmt.Print(string(out))
+	return 0
+}
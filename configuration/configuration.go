@@ -8,9 +8,20 @@ const (
 	Tag = "configuration"
 )
 
+// ProviderConfig describes the configuration sources, from the lowest to the highest
+// precedence: the Source file, the *.yaml files of Directory in lexical order, the
+// environment variables starting with EnvPrefix, then Overrides. ${VAR} references
+// are interpolated once all the sources have been merged, then secret references
+// (env:NAME and file:///path values) are replaced by the secret they refer to.
 type ProviderConfig struct {
-	Logger log.Logger
-	Source interface{}
+	Logger    log.Logger
+	Source    interface{}
+	Directory string
+	// EnvPrefix enables environment overrides, with the HEA_ prefix HEA_NATS__URL
+	// overrides nats.url and HEA_MAX_BODY_SIZE overrides maxBodySize.
+	EnvPrefix string
+	// Overrides are key=value pairs (eg: nats.url=localhost:4222).
+	Overrides []string
 }
 
 type Provider interface {
@@ -23,6 +34,14 @@ type Provider interface {
 	GetMapStringString(string) map[string]string
 	GetMapStringStrings(string) map[string][]string
 	GetMapStringBool(string) map[string]bool
-	Load(string, interface{})
+	Load(string, interface{}) error
+	// LoadStrict is like Load but fails on keys not matching any field.
+	LoadStrict(string, interface{}) error
 	Set(string, interface{})
+	// Raw returns a copy of the whole configuration.
+	Raw() map[string]interface{}
+	// Redacted returns a copy of the whole configuration without its secrets.
+	Redacted() map[string]interface{}
+	// SecretFiles returns the files read to resolve file:// secret references.
+	SecretFiles() []string
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/confmap"
 	"github.com/knadh/koanf/providers/file"
+	"github.com/mitchellh/mapstructure"
 )
 
 const delimiter = "."
@@ -14,6 +15,7 @@ const delimiter = "."
 type provider struct {
 	logger log.Logger
 	*koanf.Koanf
+	secrets *secretRefs
 }
 
 func NewKoanfProvider(c ProviderConfig) (Provider, error) {
@@ -25,8 +27,13 @@ func NewKoanfProvider(c ProviderConfig) (Provider, error) {
 		}
 	case *provider:
 		k.Merge(source.Koanf)
+		return &provider{logger: c.Logger, Koanf: k, secrets: source.secrets}, nil
 	}
-	return &provider{logger: c.Logger, Koanf: k}, nil
+	secrets, err := loadSources(k, c)
+	if err != nil {
+		return nil, err
+	}
+	return &provider{logger: c.Logger, Koanf: k, secrets: secrets}, nil
 }
 
 func (p *provider) GetBool(key string) bool {
@@ -89,10 +96,35 @@ func (p *provider) GetMapStringBool(key string) map[string]bool {
 	return v
 }
 
-func (p *provider) Load(key string, ptr interface{}) {
+func (p *provider) Load(key string, ptr interface{}) error {
 	if err := p.Koanf.UnmarshalWithConf(key, ptr, koanf.UnmarshalConf{Tag: Tag}); err != nil {
 		p.logger.Err("error while loading %s : %s", key, err.Error())
+		return err
 	}
+	return nil
+}
+
+func (p *provider) LoadStrict(key string, ptr interface{}) error {
+	return p.Koanf.UnmarshalWithConf(key, ptr, koanf.UnmarshalConf{
+		Tag: Tag,
+		DecoderConfig: &mapstructure.DecoderConfig{
+			DecodeHook: mapstructure.ComposeDecodeHookFunc(
+				mapstructure.StringToTimeDurationHookFunc(),
+				mapstructure.StringToSliceHookFunc(","),
+				mapstructure.TextUnmarshallerHookFunc()),
+			ErrorUnused:      true,
+			Result:           ptr,
+			WeaklyTypedInput: true,
+		},
+	})
+}
+
+func (p *provider) Redacted() map[string]interface{} {
+	return redactPaths(Redact(p.Raw()), "", p.secrets.paths)
+}
+
+func (p *provider) SecretFiles() []string {
+	return append([]string(nil), p.secrets.files...)
 }
 
 func (p *provider) Set(key string, value interface{}) {
@@ -0,0 +1,89 @@
+package configuration
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const redacted = "**redacted**"
+
+var secretKeys = []string{"secret", "password", "passwd", "token", "credential", "privatekey"}
+
+// Redact returns a copy of the configuration where the values of secret keys and the
+// credentials of urls are replaced.
+func Redact(cfg map[string]interface{}) map[string]interface{} {
+	return redact(cfg, false).(map[string]interface{})
+}
+
+func redact(value interface{}, secret bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[key] = redact(item, secret || isSecretKey(key))
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, item := range v {
+			l[i] = redact(item, secret)
+		}
+		return l
+	case string:
+		if secret {
+			return redacted
+		}
+		if u, err := url.Parse(v); err == nil && u.User != nil {
+			if _, hasPassword := u.User.Password(); hasPassword {
+				u.User = url.UserPassword(u.User.Username(), redacted)
+			} else {
+				u.User = url.User(redacted)
+			}
+			return u.String()
+		}
+	default:
+		if secret && value != nil {
+			return redacted
+		}
+	}
+	return value
+}
+
+// redactPaths redacts the values of the given paths.
+func redactPaths(cfg map[string]interface{}, path string, paths map[string]bool) map[string]interface{} {
+	for key, item := range cfg {
+		p := key
+		if path != "" {
+			p = path + delimiter + key
+		}
+		switch v := item.(type) {
+		case map[string]interface{}:
+			cfg[key] = redactPaths(v, p, paths)
+		case []interface{}:
+			for i := range v {
+				if paths[fmt.Sprintf("%s[%d]", p, i)] {
+					v[i] = redacted
+				}
+			}
+		default:
+			if paths[p] {
+				cfg[key] = redacted
+			}
+		}
+	}
+	return cfg
+}
+
+func isSecretKey(key string) bool {
+	k := strings.ToLower(key)
+	if k == "keys" {
+		return true
+	}
+	for _, s := range secretKeys {
+		if strings.Contains(k, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package configuration
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	envSecretPrefix  = "env:"
+	fileSecretPrefix = "file://"
+)
+
+// secretRefs records the secret references resolved in a configuration.
+type secretRefs struct {
+	// paths holds the paths of the resolved values
+	paths map[string]bool
+	// files holds the secret files which have been read
+	files []string
+}
+
+func newSecretRefs() *secretRefs {
+	return &secretRefs{paths: make(map[string]bool)}
+}
+
+// resolveSecrets replaces the secret references (env:NAME and file:///path) found in
+// the values of secret keys, and in their subtrees, by their value, and records them
+// in secrets. Other values, such as schema urls, are kept as they are.
+func resolveSecrets(value interface{}, path string, secret bool, secrets *secretRefs) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			p := key
+			if path != "" {
+				p = path + delimiter + key
+			}
+			i, err := resolveSecrets(item, p, secret || isSecretKey(key), secrets)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = i
+		}
+	case []interface{}:
+		for idx, item := range v {
+			i, err := resolveSecrets(item, fmt.Sprintf("%s[%d]", path, idx), secret, secrets)
+			if err != nil {
+				return nil, err
+			}
+			v[idx] = i
+		}
+	case string:
+		if !secret {
+			return value, nil
+		}
+		resolved, file, isReference, err := resolveSecret(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err.Error())
+		}
+		if isReference {
+			secrets.paths[path] = true
+			if file != "" {
+				secrets.files = append(secrets.files, file)
+			}
+			return resolved, nil
+		}
+	}
+	return value, nil
+}
+
+// resolveSecret returns the secret a value refers to, and the file it has been read
+// from if any.
+func resolveSecret(reference string) (string, string, bool, error) {
+	switch {
+	case strings.HasPrefix(reference, envSecretPrefix):
+		name := strings.TrimPrefix(reference, envSecretPrefix)
+		value, exists := os.LookupEnv(name)
+		if !exists {
+			return "", "", true, fmt.Errorf("secret environment variable %s is not set", name)
+		}
+		return value, "", true, nil
+	case strings.HasPrefix(reference, fileSecretPrefix):
+		u, err := url.Parse(reference)
+		if err != nil {
+			return "", "", true, fmt.Errorf("invalid secret file reference: %s", err.Error())
+		}
+		b, err := os.ReadFile(u.Path)
+		if err != nil {
+			return "", "", true, fmt.Errorf("cannot read secret file: %s", err.Error())
+		}
+		return strings.TrimRight(string(b), "\r\n"), u.Path, true, nil
+	}
+	return "", "", false, nil
+}
@@ -0,0 +1,153 @@
+package configuration
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/knadh/koanf"
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/providers/confmap"
+	"github.com/knadh/koanf/providers/env"
+	"github.com/knadh/koanf/providers/file"
+)
+
+const envDelimiter = "__"
+
+// interpolation matches ${VAR} and ${VAR:-default}, $${ being an escaped ${.
+var interpolation = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// DirectoryFiles returns the configuration files of a directory in loading order.
+func DirectoryFiles(directory string) ([]string, error) {
+	var files []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(directory, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// loadSources merges the sources over the config file, then interpolates the
+// environment variables and resolves the secret references, which are returned.
+func loadSources(k *koanf.Koanf, c ProviderConfig) (*secretRefs, error) {
+	if c.Directory != "" {
+		files, err := DirectoryFiles(c.Directory)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if err := k.Load(file.Provider(f), yaml.Parser()); err != nil {
+				return nil, fmt.Errorf("%s: %s", f, err.Error())
+			}
+		}
+	}
+	if c.EnvPrefix != "" {
+		if err := k.Load(env.Provider(c.EnvPrefix, delimiter, envKey(k, c.EnvPrefix)), nil); err != nil {
+			return nil, err
+		}
+	}
+	for _, o := range c.Overrides {
+		key, value, ok := strings.Cut(o, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid override %s (key=value expected)", o)
+		}
+		if err := k.Load(confmap.Provider(map[string]interface{}{key: value}, delimiter), nil); err != nil {
+			return nil, err
+		}
+	}
+	raw, err := interpolate(k.Raw(), "")
+	if err != nil {
+		return nil, err
+	}
+	secrets := newSecretRefs()
+	if raw, err = resolveSecrets(raw, "", false, secrets); err != nil {
+		return nil, err
+	}
+	// keys are already nested, they must not be split again
+	return secrets, k.Load(confmap.Provider(raw.(map[string]interface{}), ""), nil)
+}
+
+// envKey converts environment variable names into configuration keys, "__" separates
+// keys and "_" separates words of camelCase keys. Keys already present in the
+// configuration are matched case insensitively.
+func envKey(k *koanf.Koanf, prefix string) func(string) string {
+	known := make(map[string]string)
+	for _, key := range k.Keys() {
+		parts := strings.Split(key, delimiter)
+		for i := range parts {
+			path := strings.Join(parts[:i+1], delimiter)
+			known[normalizeKey(path)] = path
+		}
+	}
+	return func(name string) string {
+		segments := strings.Split(strings.TrimPrefix(name, prefix), envDelimiter)
+		for i, s := range segments {
+			words := strings.Split(strings.ToLower(s), "_")
+			for j := 1; j < len(words); j++ {
+				if words[j] != "" {
+					words[j] = strings.ToUpper(words[j][:1]) + words[j][1:]
+				}
+			}
+			segments[i] = strings.Join(words, "")
+		}
+		key := strings.Join(segments, delimiter)
+		if existing, exists := known[normalizeKey(key)]; exists {
+			return existing
+		}
+		return key
+	}
+}
+
+func normalizeKey(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", ""))
+}
+
+func interpolate(value interface{}, path string) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			p := key
+			if path != "" {
+				p = path + delimiter + key
+			}
+			i, err := interpolate(item, p)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = i
+		}
+	case []interface{}:
+		for idx, item := range v {
+			i, err := interpolate(item, fmt.Sprintf("%s[%d]", path, idx))
+			if err != nil {
+				return nil, err
+			}
+			v[idx] = i
+		}
+	case string:
+		var err error
+		s := interpolation.ReplaceAllStringFunc(v, func(match string) string {
+			if strings.HasPrefix(match, "$$") {
+				return match[1:]
+			}
+			m := interpolation.FindStringSubmatch(match)
+			if value, exists := os.LookupEnv(m[1]); exists {
+				return value
+			}
+			if m[2] != "" {
+				return m[3]
+			}
+			err = fmt.Errorf("%s: environment variable %s is not set", path, m[1])
+			return match
+		})
+		return s, err
+	}
+	return value, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type request struct {
+	ID     int64         `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+type response struct {
+	Version string      `json:"jsonrpc"`
+	ID      int64       `json:"id"`
+	Result  interface{} `json:"result,omitempty"`
+	Error   *rpcError   `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+var functions = map[string]func(...interface{}) (interface{}, error){
+	"Username": Username,
+}
+
+func main() {
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 1<<20), 64<<20)
+	encoder := json.NewEncoder(os.Stdout)
+	for scanner.Scan() {
+		var req request
+		resp := response{Version: "2.0"}
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp.Error = &rpcError{Code: -32700, Message: err.Error()}
+		} else if resp.ID = req.ID; req.Method == "rpc.functions" {
+			names := make([]string, 0, len(functions))
+			for name := range functions {
+				names = append(names, name)
+			}
+			resp.Result = names
+		} else if f, exists := functions[req.Method]; !exists {
+			resp.Error = &rpcError{Code: -32601, Message: "unknown function " + req.Method}
+		} else if result, err := f(req.Params...); err != nil {
+			resp.Error = &rpcError{Code: 1, Message: err.Error()}
+		} else {
+			resp.Result = result
+		}
+		if err := encoder.Encode(resp); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+	}
+}
+
+func Username(v ...interface{}) (interface{}, error) {
+	if len(v) != 2 {
+		return nil, fmt.Errorf("Username expects 2 arguments, got %d", len(v))
+	}
+	firstname, lastname := fmt.Sprint(v[0]), fmt.Sprint(v[1])
+	var username string
+	for _, f := range strings.Fields(firstname) {
+		username += string(f[0])
+	}
+	username += lastname
+	return username, nil
+}
@@ -0,0 +1,274 @@
+package extension
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skilld-labs/http-event-adapter/log"
+)
+
+const (
+	jsonrpcVersion   = "2.0"
+	functionsMethod  = "rpc.functions"
+	methodNotFound   = -32601
+	defaultTimeout   = 5 * time.Second
+	maxResponseBytes = 64 << 20
+)
+
+var (
+	mu        sync.Mutex
+	processes = make(map[string]*Process)
+	// committed and staged are the commands used by the current configuration and by
+	// the one being built.
+	committed = make(map[string]bool)
+	staged    = make(map[string]bool)
+)
+
+// Process is an extension executable providing template functions. Functions are
+// called with JSON-RPC 2.0 requests written on its stdin, one per line, and it answers
+// with one response per line on its stdout. Its stderr is forwarded to the adapter
+// stderr. It may implement the rpc.functions method, returning the list of the
+// functions it provides, so that they are checked when the configuration is loaded.
+type Process struct {
+	logger  log.Logger
+	command string
+	timeout time.Duration
+
+	mu      sync.Mutex
+	stopped bool
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	pipe    io.ReadCloser
+	stdout  *bufio.Reader
+	nextID  int64
+	// exchanging is closed once the goroutine exchanging the last request with the
+	// process has returned.
+	exchanging chan struct{}
+}
+
+type request struct {
+	Version string        `json:"jsonrpc"`
+	ID      int64         `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type response struct {
+	ID     int64           `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// Get returns the process running the given command (an executable followed by its
+// arguments), starting it if needed. Processes are shared by all the events using
+// the same command.
+func Get(logger log.Logger, command string) (*Process, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	staged[command] = true
+	if p, exists := processes[command]; exists {
+		return p, nil
+	}
+	p := &Process{logger: logger, command: command, timeout: defaultTimeout}
+	if err := p.start(); err != nil {
+		return nil, err
+	}
+	processes[command] = p
+	return p, nil
+}
+
+// Commit stops the processes which are not used by the configuration built since the
+// last Commit or Discard, it is called once the routes of that configuration are in
+// place.
+func Commit() {
+	mu.Lock()
+	defer mu.Unlock()
+	committed, staged = staged, make(map[string]bool)
+	release()
+}
+
+// Discard stops the processes started for a configuration which cannot be used.
+func Discard() {
+	mu.Lock()
+	defer mu.Unlock()
+	staged = make(map[string]bool)
+	release()
+}
+
+// release stops the processes which are not used by the current configuration.
+func release() {
+	for command, p := range processes {
+		if committed[command] {
+			continue
+		}
+		delete(processes, command)
+		p.mu.Lock()
+		p.stopped = true
+		p.stop()
+		p.mu.Unlock()
+		p.logger.Debug("extension %s is no longer used, it has been stopped", command)
+	}
+}
+
+// Functions returns the functions provided by the extension, or nil if it does not
+// implement the rpc.functions method.
+func (p *Process) Functions() ([]string, error) {
+	result, err := p.call(functionsMethod, nil)
+	if err != nil {
+		var e *rpcError
+		if errors.As(err, &e) && e.Code == methodNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var functions []string
+	if err := json.Unmarshal(result, &functions); err != nil {
+		return nil, fmt.Errorf("extension %s: invalid %s result: %s", p.command, functionsMethod, err.Error())
+	}
+	return functions, nil
+}
+
+// Func returns a template function calling the given function of the extension.
+func (p *Process) Func(name string) func(...interface{}) (interface{}, error) {
+	return func(args ...interface{}) (interface{}, error) {
+		result, err := p.call(name, args)
+		if err != nil {
+			return nil, err
+		}
+		var v interface{}
+		if err := json.Unmarshal(result, &v); err != nil {
+			return nil, fmt.Errorf("extension %s: invalid %s result: %s", p.command, name, err.Error())
+		}
+		return v, nil
+	}
+}
+
+func (p *Process) start() error {
+	args := strings.Fields(p.command)
+	if len(args) == 0 {
+		return errors.New("extension: empty command")
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("extension %s: %s", p.command, err.Error())
+	}
+	p.cmd, p.stdin, p.pipe, p.stdout = cmd, stdin, stdout, bufio.NewReader(stdout)
+	p.logger.Debug("extension %s started (pid %d)", p.command, cmd.Process.Pid)
+	return nil
+}
+
+// stop kills the process, it is restarted by the next call. The pipes are closed so
+// that a pending exchange returns, the process being waited for once it has.
+func (p *Process) stop() {
+	if p.cmd == nil {
+		return
+	}
+	p.cmd.Process.Kill()
+	p.stdin.Close()
+	p.pipe.Close()
+	if p.exchanging != nil {
+		<-p.exchanging
+		p.exchanging = nil
+	}
+	p.cmd.Wait()
+	p.cmd = nil
+}
+
+func (p *Process) call(method string, params []interface{}) (json.RawMessage, error) {
+	if params == nil {
+		params = []interface{}{}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return nil, fmt.Errorf("extension %s: %s: extension is no longer used", p.command, method)
+	}
+	if p.cmd == nil {
+		if err := p.start(); err != nil {
+			return nil, err
+		}
+	}
+	p.nextID++
+	req, err := json.Marshal(request{Version: jsonrpcVersion, ID: p.nextID, Method: method, Params: params})
+	if err != nil {
+		return nil, fmt.Errorf("extension %s: %s: %s", p.command, method, err.Error())
+	}
+	type result struct {
+		resp response
+		err  error
+	}
+	done := make(chan result, 1)
+	exchanging := make(chan struct{})
+	p.exchanging = exchanging
+	go func(stdin io.Writer, stdout *bufio.Reader) {
+		defer close(exchanging)
+		var r result
+		if _, r.err = stdin.Write(append(req, '\n')); r.err == nil {
+			var line []byte
+			if line, r.err = readLine(stdout, maxResponseBytes); r.err == nil {
+				r.err = json.Unmarshal(line, &r.resp)
+			}
+		}
+		done <- r
+	}(p.stdin, p.stdout)
+	var r result
+	select {
+	case r = <-done:
+	case <-time.After(p.timeout):
+		r.err = fmt.Errorf("timeout after %s", p.timeout)
+	}
+	if r.err == nil && r.resp.ID != p.nextID {
+		r.err = fmt.Errorf("unexpected response id %d", r.resp.ID)
+	}
+	if r.err != nil {
+		// the process state is unknown, it is restarted by the next call
+		p.logger.Err("extension %s: %s: %s, restarting it", p.command, method, r.err.Error())
+		p.stop()
+		return nil, fmt.Errorf("extension %s: %s: %s", p.command, method, r.err.Error())
+	}
+	if r.resp.Error != nil {
+		return nil, fmt.Errorf("extension %s: %s: %w", p.command, method, r.resp.Error)
+	}
+	return r.resp.Result, nil
+}
+
+// readLine reads a response line, failing if it is longer than max bytes.
+func readLine(r *bufio.Reader, max int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > max {
+			return nil, fmt.Errorf("response exceeds %d bytes", max)
+		}
+		line = append(line, chunk...)
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package format
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+var ocfMagic = []byte("Obj\x01")
+
+type avroFormatter struct {
+	codec *goavro.Codec
+}
+
+func init() {
+	Register("avro", NewAvroFormatter, "application/avro", "avro/binary")
+	RegisterEncoder("avro", NewAvroEncoder)
+}
+
+// NewAvroFormatter creates a formatter decoding Avro object container files, or
+// datums of the avro.schema schema file, concatenated for multiple inputs.
+func NewAvroFormatter(cfg *FormatterConfiguration) (Formatter, error) {
+	a := &avroFormatter{}
+	if cfg.Config.GetString("avro.schema") != "" {
+		codec, err := loadAvroCodec(cfg)
+		if err != nil {
+			return nil, err
+		}
+		a.codec = codec
+	}
+	return a, nil
+}
+
+func (a *avroFormatter) FormatSingle(data []byte) (map[string]interface{}, error) {
+	var d map[string]interface{}
+	err := a.FormatStream(bytes.NewReader(data), func(elem interface{}) error {
+		if d != nil {
+			return errors.New("avro input has several records")
+		}
+		d = elem.(map[string]interface{})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, errors.New("avro input has no record")
+	}
+	return d, nil
+}
+
+func (a *avroFormatter) FormatMultiple(data []byte) ([]interface{}, error) {
+	return formatMultiple(a, data)
+}
+
+// FormatStream calls fn with each record as it is read. Object container files are
+// read with their own schema.
+func (a *avroFormatter) FormatStream(r io.Reader, fn func(interface{}) error) error {
+	reader := bufio.NewReader(r)
+	if magic, err := reader.Peek(len(ocfMagic)); err == nil && bytes.Equal(magic, ocfMagic) {
+		ocf, err := goavro.NewOCFReader(reader)
+		if err != nil {
+			return err
+		}
+		for ocf.Scan() {
+			datum, err := ocf.Read()
+			if err != nil {
+				return err
+			}
+			if err := a.record(datum, fn); err != nil {
+				return err
+			}
+		}
+		return ocf.Err()
+	}
+	if a.codec == nil {
+		return errors.New("avro.schema: is required to read avro datums outside of object container files")
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	for len(data) > 0 {
+		datum, rest, err := a.codec.NativeFromBinary(data)
+		if err != nil {
+			return err
+		}
+		if len(rest) == len(data) {
+			// datums of schemas such as empty records are encoded in zero bytes
+			return errors.New("avro datum does not consume any byte, the input cannot be split into datums")
+		}
+		data = rest
+		if err := a.record(datum, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *avroFormatter) record(datum interface{}, fn func(interface{}) error) error {
+	m, isMap := datum.(map[string]interface{})
+	if !isMap {
+		return fmt.Errorf("avro input is not a record: %T", datum)
+	}
+	return fn(m)
+}
+
+type avroEncoder struct {
+	codec *goavro.Codec
+}
+
+// NewAvroEncoder creates an encoder serializing outputs, rendered in the Avro JSON
+// encoding, as datums of the avro.schema schema file.
+func NewAvroEncoder(cfg *FormatterConfiguration) (Encoder, error) {
+	codec, err := loadAvroCodec(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &avroEncoder{codec: codec}, nil
+}
+
+func (a *avroEncoder) Encode(data []byte) ([]byte, error) {
+	datum, _, err := a.codec.NativeFromTextual(data)
+	if err != nil {
+		return nil, err
+	}
+	return a.codec.BinaryFromNative(nil, datum)
+}
+
+func loadAvroCodec(cfg *FormatterConfiguration) (*goavro.Codec, error) {
+	file := cfg.Config.GetString("avro.schema")
+	if file == "" {
+		return nil, errors.New("avro.schema: is required")
+	}
+	schema, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("avro.schema: %s", err.Error())
+	}
+	codec, err := goavro.NewCodec(string(schema))
+	if err != nil {
+		return nil, fmt.Errorf("avro.schema: %s", err.Error())
+	}
+	return codec, nil
+}
@@ -0,0 +1,39 @@
+package format
+
+import (
+	"io"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+type cborFormatter struct{}
+
+func init() {
+	Register("cbor", NewCborFormatter, "application/cbor")
+}
+
+func NewCborFormatter(cfg *FormatterConfiguration) (Formatter, error) {
+	return &cborFormatter{}, nil
+}
+
+func (c *cborFormatter) FormatSingle(data []byte) (map[string]interface{}, error) {
+	var d interface{}
+	if err := cbor.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+	return normalizedMap(d)
+}
+
+func (c *cborFormatter) FormatMultiple(data []byte) ([]interface{}, error) {
+	return formatMultiple(c, data)
+}
+
+// FormatStream calls fn with each item of the CBOR sequence, the elements of top
+// level arrays being read as items.
+func (c *cborFormatter) FormatStream(r io.Reader, fn func(interface{}) error) error {
+	dec := cbor.NewDecoder(r)
+	return decodeValues(func() (interface{}, error) {
+		var d interface{}
+		return d, dec.Decode(&d)
+	}, fn)
+}
@@ -1,67 +1,283 @@
 package format
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/csv"
 	"errors"
+	"fmt"
 	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/skilld-labs/http-event-adapter/log"
 )
 
 const (
 	defaultSeparator = ','
+	defaultQuote     = '"'
+
+	// RaggedRowsReject rejects the input when a row does not have as many fields as
+	// there are columns.
+	RaggedRowsReject = "reject"
+	// RaggedRowsFill completes short rows with empty values and drops the extra
+	// fields of long rows.
+	RaggedRowsFill = "fill"
+	// RaggedRowsSkip drops ragged rows, reporting them in the logs.
+	RaggedRowsSkip = "skip"
 )
 
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
 type csvFormatter struct {
-	separator rune
+	logger     log.Logger
+	separator  rune
+	quote      byte
+	comment    rune
+	header     bool
+	columns    []string
+	types      map[string]string
+	timeFormat string
+	trim       bool
+	lazyQuotes bool
+	raggedRows string
+}
+
+func init() {
+	Register("csv", NewCsvFormatter, "text/csv", "application/csv")
 }
 
 func NewCsvFormatter(cfg *FormatterConfiguration) (Formatter, error) {
-	separator := defaultSeparator
+	c := &csvFormatter{
+		logger:     cfg.Logger,
+		separator:  defaultSeparator,
+		quote:      defaultQuote,
+		header:     true,
+		timeFormat: time.RFC3339,
+		raggedRows: RaggedRowsReject,
+	}
 	if sep := cfg.Config.GetString("csv.separator"); sep != "" {
-		separator = rune(sep[0])
+		if sep == `\t` {
+			sep = "\t"
+		}
+		separator, err := singleRune(sep)
+		if err != nil {
+			return nil, fmt.Errorf("csv.separator: %s", err.Error())
+		}
+		c.separator = separator
+	}
+	if quote := cfg.Config.GetString("csv.quote"); quote != "" {
+		if len(quote) != 1 || quote[0] >= 0x80 {
+			return nil, fmt.Errorf("csv.quote: must be a single ASCII character")
+		}
+		c.quote = quote[0]
+	}
+	if comment := cfg.Config.GetString("csv.comment"); comment != "" {
+		r, err := singleRune(comment)
+		if err != nil {
+			return nil, fmt.Errorf("csv.comment: %s", err.Error())
+		}
+		c.comment = r
+	}
+	if cfg.Config.Get("csv.header") != nil {
+		c.header = cfg.Config.GetBool("csv.header")
 	}
-	return &csvFormatter{separator: separator}, nil
+	c.columns = cfg.Config.GetStrings("csv.columns")
+	if !c.header && len(c.columns) == 0 {
+		return nil, errors.New("csv.columns: are required without header")
+	}
+	c.types = cfg.Config.GetMapStringString("csv.types")
+	for column, t := range c.types {
+		switch t {
+		case "string", "int", "float", "bool", "time":
+		default:
+			return nil, fmt.Errorf("csv.types: unsupported type %s for column %s (string, int, float, bool or time)", t, column)
+		}
+	}
+	if timeFormat := cfg.Config.GetString("csv.timeFormat"); timeFormat != "" {
+		c.timeFormat = timeFormat
+	}
+	c.trim = cfg.Config.GetBool("csv.trim")
+	c.lazyQuotes = cfg.Config.GetBool("csv.lazyQuotes")
+	if raggedRows := cfg.Config.GetString("csv.raggedRows"); raggedRows != "" {
+		switch raggedRows {
+		case RaggedRowsReject, RaggedRowsFill, RaggedRowsSkip:
+		default:
+			return nil, fmt.Errorf("csv.raggedRows: unsupported policy %s (reject, fill or skip)", raggedRows)
+		}
+		c.raggedRows = raggedRows
+	}
+	if c.separator == rune(c.quote) || c.comment == rune(c.quote) {
+		return nil, errors.New("csv.quote: cannot be the separator or the comment character")
+	}
+	if c.quote != defaultQuote && (c.separator == defaultQuote || c.comment == defaultQuote) {
+		// the custom quote character and '"' are swapped while reading
+		return nil, errors.New(`csv.quote: the separator or the comment character cannot be '"' with a custom quote`)
+	}
+	if c.separator == '\r' || c.separator == '\n' || c.comment == '\r' || c.comment == '\n' {
+		return nil, errors.New("csv: the separator and the comment character cannot be line breaks")
+	}
+	if c.comment == c.separator {
+		return nil, errors.New("csv.comment: cannot be the separator")
+	}
+	return c, nil
 }
 
-func (c *csvFormatter) FormatSingle(data []byte) (map[string]interface{}, error) {
-	return nil, errors.New("cannot parse single input")
+// singleRune returns the character of an option made of a single character.
+func singleRune(value string) (rune, error) {
+	r, size := utf8.DecodeRuneInString(value)
+	if r == utf8.RuneError || size != len(value) {
+		return 0, fmt.Errorf("must be a single character, got %q", value)
+	}
+	return r, nil
 }
 
-func (c *csvFormatter) FormatMultiple(data []byte) ([]interface{}, error) {
-	r := bytes.NewReader(data)
-	mm, err := c.csvToMaps(r)
+// FormatSingle reads a document made of a single row.
+func (c *csvFormatter) FormatSingle(data []byte) (map[string]interface{}, error) {
+	var d map[string]interface{}
+	err := c.FormatStream(bytes.NewReader(data), func(row interface{}) error {
+		if d != nil {
+			return errors.New("csv input has several rows")
+		}
+		d = row.(map[string]interface{})
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	var d []interface{}
-	for _, m := range mm {
-		d = append(d, m)
+	if d == nil {
+		return nil, errors.New("csv input has no row")
 	}
-	return d, err
+	return d, nil
+}
+
+func (c *csvFormatter) FormatMultiple(data []byte) ([]interface{}, error) {
+	return formatMultiple(c, data)
 }
 
-func (c *csvFormatter) csvToMaps(reader io.Reader) ([]map[string]string, error) {
+// FormatStream calls fn with each row of the CSV document as it is read.
+func (c *csvFormatter) FormatStream(reader io.Reader, fn func(interface{}) error) error {
+	br := bufio.NewReader(reader)
+	if bom, err := br.Peek(len(utf8BOM)); err == nil && bytes.Equal(bom, utf8BOM) {
+		br.Discard(len(utf8BOM))
+	}
+	reader = br
+	if c.quote != defaultQuote {
+		reader = &swapReader{reader: reader, a: c.quote, b: defaultQuote}
+	}
 	r := csv.NewReader(reader)
 	r.Comma = c.separator
-	rows := []map[string]string{}
-	var header []string
+	r.Comment = c.comment
+	r.LazyQuotes = c.lazyQuotes
+	r.TrimLeadingSpace = c.trim
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = true
+	columns := c.columns
+	readHeader := c.header
 	for {
 		record, err := r.Read()
 		if err == io.EOF {
-			break
+			return nil
 		}
 		if err != nil {
-			return nil, err
+			return err
+		}
+		line, _ := r.FieldPos(0)
+		if readHeader {
+			readHeader = false
+			if len(columns) == 0 {
+				columns = make([]string, len(record))
+				for i, field := range record {
+					columns[i] = c.field(field)
+				}
+			}
+			continue
+		}
+		if len(record) != len(columns) {
+			switch c.raggedRows {
+			case RaggedRowsReject:
+				return fmt.Errorf("csv line %d: %d fields for %d columns", line, len(record), len(columns))
+			case RaggedRowsSkip:
+				c.logger.Err("csv line %d: %d fields for %d columns (skipped)", line, len(record), len(columns))
+				continue
+			}
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			var field string
+			if i < len(record) {
+				field = c.field(record[i])
+			}
+			v, err := c.value(column, field)
+			if err != nil {
+				return fmt.Errorf("csv line %d, column %s: %s", line, column, err.Error())
+			}
+			row[column] = v
+		}
+		if err := fn(row); err != nil {
+			return err
 		}
-		if header == nil {
-			header = record
-		} else {
-			dict := map[string]string{}
-			for i := range header {
-				dict[header[i]] = record[i]
+	}
+}
+
+// field restores the quotes swapped to be read by encoding/csv and trims the field.
+func (c *csvFormatter) field(field string) string {
+	if c.quote != defaultQuote {
+		field = strings.Map(func(r rune) rune {
+			switch r {
+			case rune(c.quote):
+				return defaultQuote
+			case defaultQuote:
+				return rune(c.quote)
 			}
-			rows = append(rows, dict)
+			return r
+		}, field)
+	}
+	if c.trim {
+		field = strings.TrimSpace(field)
+	}
+	return field
+}
+
+// value converts the field to the type of its column, empty fields of typed columns
+// are nil.
+func (c *csvFormatter) value(column, field string) (interface{}, error) {
+	t := c.types[column]
+	if t == "" || t == "string" {
+		return field, nil
+	}
+	if field == "" {
+		return nil, nil
+	}
+	switch t {
+	case "int":
+		return strconv.ParseInt(field, 10, 64)
+	case "float":
+		return strconv.ParseFloat(field, 64)
+	case "bool":
+		return strconv.ParseBool(field)
+	}
+	return time.Parse(c.timeFormat, field)
+}
+
+// swapReader exchanges two ASCII characters, so that encoding/csv, which only knows
+// the double quote, can read documents using another quote character.
+type swapReader struct {
+	reader io.Reader
+	a, b   byte
+}
+
+func (s *swapReader) Read(p []byte) (int, error) {
+	n, err := s.reader.Read(p)
+	for i := 0; i < n; i++ {
+		switch p[i] {
+		case s.a:
+			p[i] = s.b
+		case s.b:
+			p[i] = s.a
 		}
 	}
-	return rows, nil
+	return n, err
 }
@@ -0,0 +1,35 @@
+package format
+
+import (
+	"errors"
+	"net/url"
+)
+
+type formFormatter struct{}
+
+func init() {
+	Register("form", NewFormFormatter, "application/x-www-form-urlencoded")
+}
+
+func NewFormFormatter(cfg *FormatterConfiguration) (Formatter, error) {
+	return &formFormatter{}, nil
+}
+
+func (f *formFormatter) FormatSingle(data []byte) (map[string]interface{}, error) {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return nil, err
+	}
+	return FormatValues(values), nil
+}
+
+func (f *formFormatter) FormatMultiple(data []byte) ([]interface{}, error) {
+	d, err := f.FormatSingle(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(d) == 0 {
+		return nil, errors.New("form input has no field")
+	}
+	return []interface{}{d}, nil
+}
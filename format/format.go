@@ -1,12 +1,28 @@
 package format
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"sync"
 
 	"github.com/skilld-labs/http-event-adapter/configuration"
 	"github.com/skilld-labs/http-event-adapter/log"
 )
 
+// Auto is the inputFormat selecting the formatter from the request Content-Type.
+const Auto = "auto"
+
+var (
+	mu           sync.RWMutex
+	factories    = make(map[string]Factory)
+	contentTypes = make(map[string]string)
+	encoders     = make(map[string]EncoderFactory)
+)
+
 type FormatterConfiguration struct {
 	Logger log.Logger
 	Config configuration.Provider
@@ -17,18 +33,125 @@ type Formatter interface {
 	FormatMultiple([]byte) ([]interface{}, error)
 }
 
+// StreamFormatter is implemented by the formatters able to read the elements of a
+// multiple input document one at a time, without loading the whole document.
+type StreamFormatter interface {
+	FormatStream(r io.Reader, fn func(interface{}) error) error
+}
+
+// ContentTypeFormatter is implemented by the formatters needing the parameters of the
+// Content-Type of the request, eg: the boundary of multipart documents.
+type ContentTypeFormatter interface {
+	WithContentType(contentType string) (Formatter, error)
+}
+
+// SkippedError is returned by the formatters which skipped invalid parts of the
+// input, along with the elements read from the valid ones.
+type SkippedError struct {
+	Errors []string
+}
+
+func (e *SkippedError) Error() string {
+	return fmt.Sprintf("%d invalid parts skipped: %s", len(e.Errors), strings.Join(e.Errors, ", "))
+}
+
+// formatMultiple reads all the elements of a stream formatter.
+func formatMultiple(f StreamFormatter, data []byte) ([]interface{}, error) {
+	var d []interface{}
+	err := f.FormatStream(bytes.NewReader(data), func(elem interface{}) error {
+		d = append(d, elem)
+		return nil
+	})
+	var skipped *SkippedError
+	if err != nil && !errors.As(err, &skipped) {
+		return nil, err
+	}
+	return d, err
+}
+
+// Factory creates a formatter, it is called for each event using the format.
+type Factory func(*FormatterConfiguration) (Formatter, error)
+
+// Register makes a formatter available under the given inputFormat name, optionally
+// selected by inputFormat auto for the given media types. It is meant to be called
+// from the init function of the package providing the formatter, and panics if the
+// name or a media type is already registered.
+func Register(name string, factory Factory, mediaTypes ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if factory == nil {
+		panic("format: Register factory is nil for " + name)
+	}
+	if _, exists := factories[name]; exists || name == Auto {
+		panic("format: Register called twice for " + name)
+	}
+	for _, mediaType := range mediaTypes {
+		if _, exists := contentTypes[mediaType]; exists {
+			panic("format: Register called twice for media type " + mediaType)
+		}
+	}
+	factories[name] = factory
+	for _, mediaType := range mediaTypes {
+		contentTypes[mediaType] = name
+	}
+}
+
 func GetFormatter(cfg *FormatterConfiguration, name string) (Formatter, error) {
-	var formatter Formatter
-	var err error
-	switch name {
-	case "json":
-		formatter, err = NewJsonFormatter(cfg)
-	case "yaml":
-		formatter, err = NewYamlFormatter(cfg)
-	case "csv":
-		formatter, err = NewCsvFormatter(cfg)
-	default:
-		err = fmt.Errorf("unknown inputFormat %s", name)
-	}
-	return formatter, err
+	mu.RLock()
+	factory, exists := factories[name]
+	mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("unknown inputFormat %s", name)
+	}
+	return factory(cfg)
+}
+
+// GetFormatterName returns the name of the formatter handling the given Content-Type header.
+func GetFormatterName(contentType string) (string, error) {
+	if contentType == "" {
+		return "", fmt.Errorf("cannot select input format automatically: no Content-Type")
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", err
+	}
+	mu.RLock()
+	name, exists := contentTypes[mediaType]
+	mu.RUnlock()
+	if !exists {
+		return "", fmt.Errorf("no input format for Content-Type %s", mediaType)
+	}
+	return name, nil
+}
+
+// Encoder serializes the outputs rendered as JSON documents.
+type Encoder interface {
+	Encode([]byte) ([]byte, error)
+}
+
+// EncoderFactory creates an encoder, it is called for each event using the encoding.
+type EncoderFactory func(*FormatterConfiguration) (Encoder, error)
+
+// RegisterEncoder makes an encoder available under the given outputEncoding name. Like
+// Register, it panics if the name is already registered.
+func RegisterEncoder(name string, factory EncoderFactory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if factory == nil {
+		panic("format: RegisterEncoder factory is nil for " + name)
+	}
+	if _, exists := encoders[name]; exists {
+		panic("format: RegisterEncoder called twice for " + name)
+	}
+	encoders[name] = factory
+}
+
+func GetEncoder(cfg *FormatterConfiguration, name string) (Encoder, error) {
+	mu.RLock()
+	factory, exists := encoders[name]
+	mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("unknown outputEncoding %s", name)
+	}
+	return factory(cfg)
 }
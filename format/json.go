@@ -1,19 +1,118 @@
 package format
 
-import "encoding/json"
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
 
-type jsonFormatter struct{}
+type jsonFormatter struct {
+	useNumber            bool
+	wrapSingle           bool
+	disallowTrailingData bool
+}
+
+func init() {
+	Register("json", NewJsonFormatter, "application/json", "text/json")
+}
 
 func NewJsonFormatter(cfg *FormatterConfiguration) (Formatter, error) {
-	return &jsonFormatter{}, nil
+	j := &jsonFormatter{disallowTrailingData: true}
+	j.useNumber = cfg.Config.GetBool("json.useNumber")
+	j.wrapSingle = cfg.Config.GetBool("json.wrapSingle")
+	if cfg.Config.Get("json.disallowTrailingData") != nil {
+		j.disallowTrailingData = cfg.Config.GetBool("json.disallowTrailingData")
+	}
+	return j, nil
 }
 
 func (j *jsonFormatter) FormatSingle(data []byte) (map[string]interface{}, error) {
+	dec := j.newDecoder(bytes.NewReader(data))
 	var d map[string]interface{}
-	return d, json.Unmarshal(data, &d)
+	if err := dec.Decode(&d); err != nil {
+		return nil, err
+	}
+	if err := j.end(dec); err != nil {
+		return nil, err
+	}
+	return d, nil
 }
 
 func (j *jsonFormatter) FormatMultiple(data []byte) ([]interface{}, error) {
-	var d []interface{}
-	return d, json.Unmarshal(data, &d)
+	return formatMultiple(j, data)
+}
+
+// FormatStream calls fn with each element of the JSON array as it is read, or with
+// the object if it is not in an array and wrapSingle is set.
+func (j *jsonFormatter) FormatStream(r io.Reader, fn func(interface{}) error) error {
+	reader := bufio.NewReader(r)
+	dec := j.newDecoder(reader)
+	if j.wrapSingle {
+		if first, err := firstByte(reader); err == nil && first == '{' {
+			var elem map[string]interface{}
+			if err := dec.Decode(&elem); err != nil {
+				return err
+			}
+			if err := j.end(dec); err != nil {
+				return err
+			}
+			return fn(elem)
+		}
+	}
+	if t, err := dec.Token(); err != nil {
+		return err
+	} else if t != json.Delim('[') {
+		return errors.New("json input is not an array")
+	}
+	for dec.More() {
+		var elem interface{}
+		if err := dec.Decode(&elem); err != nil {
+			return err
+		}
+		if err := fn(elem); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	return j.end(dec)
+}
+
+func (j *jsonFormatter) newDecoder(r io.Reader) *json.Decoder {
+	dec := json.NewDecoder(r)
+	if j.useNumber {
+		dec.UseNumber()
+	}
+	return dec
+}
+
+// end checks that nothing but spaces follows the decoded value, unless trailing
+// data is allowed.
+func (j *jsonFormatter) end(dec *json.Decoder) error {
+	if !j.disallowTrailingData {
+		return nil
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("invalid data after top-level json value")
+	}
+	return nil
+}
+
+// firstByte returns the first byte of the reader which is not a space, without
+// consuming it.
+func firstByte(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b, r.UnreadByte()
+	}
 }
@@ -0,0 +1,50 @@
+package format
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type msgpackFormatter struct{}
+
+func init() {
+	Register("msgpack", NewMsgpackFormatter, "application/msgpack", "application/x-msgpack", "application/vnd.msgpack")
+}
+
+func NewMsgpackFormatter(cfg *FormatterConfiguration) (Formatter, error) {
+	return &msgpackFormatter{}, nil
+}
+
+func (m *msgpackFormatter) FormatSingle(data []byte) (map[string]interface{}, error) {
+	var d interface{}
+	if err := newMsgpackDecoder(bytes.NewReader(data)).Decode(&d); err != nil {
+		return nil, err
+	}
+	return normalizedMap(d)
+}
+
+func (m *msgpackFormatter) FormatMultiple(data []byte) ([]interface{}, error) {
+	return formatMultiple(m, data)
+}
+
+// FormatStream calls fn with each value of the stream of msgpack values, the
+// elements of top level arrays being read as values.
+func (m *msgpackFormatter) FormatStream(r io.Reader, fn func(interface{}) error) error {
+	dec := newMsgpackDecoder(r)
+	return decodeValues(func() (interface{}, error) {
+		var d interface{}
+		return d, dec.Decode(&d)
+	}, fn)
+}
+
+// newMsgpackDecoder returns a decoder accepting maps whose keys are not strings,
+// they are converted by Normalize.
+func newMsgpackDecoder(r io.Reader) *msgpack.Decoder {
+	dec := msgpack.NewDecoder(r)
+	dec.SetMapDecoder(func(d *msgpack.Decoder) (interface{}, error) {
+		return d.DecodeUntypedMap()
+	})
+	return dec
+}
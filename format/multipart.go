@@ -0,0 +1,130 @@
+package format
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+)
+
+type multipartFormatter struct {
+	boundary string
+	// parsers are the formatters reading the files, by name.
+	parsers map[string]Formatter
+}
+
+func init() {
+	Register("multipart", NewMultipartFormatter, "multipart/form-data")
+}
+
+// NewMultipartFormatter creates a formatter exposing fields as values and files as
+// maps holding their filename, contentType and either their base64 content, or the
+// elements read by one of the formats listed in multipart.parse when their content
+// type is one of its media types.
+func NewMultipartFormatter(cfg *FormatterConfiguration) (Formatter, error) {
+	m := &multipartFormatter{parsers: map[string]Formatter{}}
+	for _, name := range cfg.Config.GetStrings("multipart.parse") {
+		if name == "multipart" {
+			return nil, errors.New("multipart.parse: files cannot be parsed as multipart")
+		}
+		f, err := GetFormatter(cfg, name)
+		if err != nil {
+			return nil, fmt.Errorf("multipart.parse: %s", err.Error())
+		}
+		m.parsers[name] = f
+	}
+	return m, nil
+}
+
+// WithContentType returns a formatter using the boundary of the Content-Type.
+func (m *multipartFormatter) WithContentType(contentType string) (Formatter, error) {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, err
+	}
+	if params["boundary"] == "" {
+		return nil, errors.New("multipart input has no boundary")
+	}
+	return &multipartFormatter{boundary: params["boundary"], parsers: m.parsers}, nil
+}
+
+func (m *multipartFormatter) FormatSingle(data []byte) (map[string]interface{}, error) {
+	if m.boundary == "" {
+		return nil, errors.New("multipart input requires the boundary of the Content-Type")
+	}
+	d := map[string]interface{}{}
+	r := multipart.NewReader(bytes.NewReader(data), m.boundary)
+	for {
+		part, err := r.NextPart()
+		if err == io.EOF {
+			return d, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		v, err := m.formatPart(part)
+		if err != nil {
+			return nil, fmt.Errorf("multipart part %s: %s", part.FormName(), err.Error())
+		}
+		addValue(d, part.FormName(), v)
+	}
+}
+
+func (m *multipartFormatter) FormatMultiple(data []byte) ([]interface{}, error) {
+	d, err := m.FormatSingle(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(d) == 0 {
+		return nil, errors.New("multipart input has no part")
+	}
+	return []interface{}{d}, nil
+}
+
+func (m *multipartFormatter) formatPart(part *multipart.Part) (interface{}, error) {
+	content, err := io.ReadAll(part)
+	if err != nil {
+		return nil, err
+	}
+	if part.FileName() == "" {
+		return string(content), nil
+	}
+	contentType := part.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	file := map[string]interface{}{
+		"filename":    part.FileName(),
+		"contentType": contentType,
+		"size":        len(content),
+	}
+	if name, err := GetFormatterName(contentType); err == nil && m.parsers[name] != nil {
+		elems, err := m.parsers[name].FormatMultiple(content)
+		// skipped parts have been reported by the parser
+		var skipped *SkippedError
+		if err != nil && !errors.As(err, &skipped) {
+			return nil, err
+		}
+		file["data"] = elems
+		return file, nil
+	}
+	file["content"] = base64.StdEncoding.EncodeToString(content)
+	return file, nil
+}
+
+// addValue sets a field, repeated fields are lists.
+func addValue(d map[string]interface{}, name string, v interface{}) {
+	existing, exists := d[name]
+	if !exists {
+		d[name] = v
+		return
+	}
+	if l, isList := existing.([]interface{}); isList {
+		d[name] = append(l, v)
+		return
+	}
+	d[name] = []interface{}{existing, v}
+}
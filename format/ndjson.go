@@ -0,0 +1,102 @@
+package format
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/skilld-labs/http-event-adapter/log"
+)
+
+const (
+	// OnErrorReject rejects the whole input when a line cannot be parsed.
+	OnErrorReject = "reject"
+	// OnErrorSkip drops the lines which cannot be parsed, reporting them in the logs
+	// and in a SkippedError.
+	OnErrorSkip = "skip"
+)
+
+type ndjsonFormatter struct {
+	logger         log.Logger
+	skipBlankLines bool
+	onError        string
+}
+
+func init() {
+	Register("ndjson", NewNdjsonFormatter, "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines")
+}
+
+func NewNdjsonFormatter(cfg *FormatterConfiguration) (Formatter, error) {
+	n := &ndjsonFormatter{logger: cfg.Logger, skipBlankLines: true, onError: OnErrorReject}
+	if cfg.Config.Get("ndjson.skipBlankLines") != nil {
+		n.skipBlankLines = cfg.Config.GetBool("ndjson.skipBlankLines")
+	}
+	if onError := cfg.Config.GetString("ndjson.onError"); onError != "" {
+		if onError != OnErrorReject && onError != OnErrorSkip {
+			return nil, fmt.Errorf("ndjson.onError: unsupported policy %s (reject or skip)", onError)
+		}
+		n.onError = onError
+	}
+	return n, nil
+}
+
+func (n *ndjsonFormatter) FormatSingle(data []byte) (map[string]interface{}, error) {
+	var d map[string]interface{}
+	return d, json.Unmarshal(data, &d)
+}
+
+func (n *ndjsonFormatter) FormatMultiple(data []byte) ([]interface{}, error) {
+	return formatMultiple(n, data)
+}
+
+// FormatStream calls fn with the value of each line as it is read. When invalid
+// lines are skipped, their errors are returned once all the lines have been read.
+func (n *ndjsonFormatter) FormatStream(r io.Reader, fn func(interface{}) error) error {
+	reader := bufio.NewReader(r)
+	var skipped []string
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(data) > 0 {
+			elem, blank, perr := n.formatLine(line, data)
+			switch {
+			case perr != nil && n.onError == OnErrorSkip:
+				n.logger.Err("%s (skipped)", perr.Error())
+				skipped = append(skipped, perr.Error())
+			case perr != nil:
+				return perr
+			case !blank:
+				if err := fn(elem); err != nil {
+					return err
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	if len(skipped) > 0 {
+		return &SkippedError{Errors: skipped}
+	}
+	return nil
+}
+
+// formatLine returns the value of a line, or whether it is a blank line to skip.
+func (n *ndjsonFormatter) formatLine(line int, data []byte) (interface{}, bool, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		if n.skipBlankLines {
+			return nil, true, nil
+		}
+		return nil, false, fmt.Errorf("ndjson line %d: blank line", line)
+	}
+	var elem interface{}
+	if err := json.Unmarshal(data, &elem); err != nil {
+		return nil, false, fmt.Errorf("ndjson line %d: %s", line, err.Error())
+	}
+	return elem, false, nil
+}
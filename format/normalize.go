@@ -0,0 +1,73 @@
+package format
+
+import (
+	"fmt"
+	"io"
+)
+
+// Normalize converts recursively the maps whose keys are not strings, as decoded from
+// YAML, msgpack or CBOR documents, into map[string]interface{} so that the documents
+// are handled like JSON ones by templates and can be encoded in JSON.
+func Normalize(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			m[fmt.Sprint(k)] = Normalize(item)
+		}
+		return m
+	case map[string]interface{}:
+		for k, item := range value {
+			value[k] = Normalize(item)
+		}
+		return value
+	case []interface{}:
+		for i, item := range value {
+			value[i] = Normalize(item)
+		}
+		return value
+	}
+	return v
+}
+
+// normalizedMap returns the normalized document if it is a map.
+func normalizedMap(v interface{}) (map[string]interface{}, error) {
+	m, isMap := Normalize(v).(map[string]interface{})
+	if !isMap {
+		return nil, fmt.Errorf("input is not a map: %T", v)
+	}
+	return m, nil
+}
+
+// decodeValues calls fn with the normalized values returned by decode until it
+// returns io.EOF, the elements of arrays being passed one by one.
+func decodeValues(decode func() (interface{}, error), fn func(interface{}) error) error {
+	for {
+		d, err := decode()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := eachElement(d, fn); err != nil {
+			return err
+		}
+	}
+}
+
+// eachElement calls fn with the normalized value, or with each of its elements if it
+// is a list.
+func eachElement(v interface{}, fn func(interface{}) error) error {
+	v = Normalize(v)
+	elems, isList := v.([]interface{})
+	if !isList {
+		return fn(v)
+	}
+	for _, elem := range elems {
+		if err := fn(elem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
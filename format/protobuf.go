@@ -0,0 +1,172 @@
+package format
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/skilld-labs/http-event-adapter/configuration"
+)
+
+const (
+	defaultProtobufMaxMessageSize = 4 << 20
+)
+
+type protobufFormatter struct {
+	message        protoreflect.MessageDescriptor
+	delimited      bool
+	maxMessageSize int64
+}
+
+func init() {
+	Register("protobuf", NewProtobufFormatter, "application/x-protobuf", "application/protobuf")
+	RegisterEncoder("protobuf", NewProtobufEncoder)
+}
+
+// NewProtobufFormatter creates a formatter decoding the protobuf.message message type
+// of the protobuf.descriptorSet file. Multiple inputs are streams of size delimited
+// messages when protobuf.delimited is set, a single message otherwise. Delimited
+// messages larger than protobuf.maxMessageSize (4MB by default) are rejected.
+func NewProtobufFormatter(cfg *FormatterConfiguration) (Formatter, error) {
+	message, err := loadMessageDescriptor(cfg)
+	if err != nil {
+		return nil, err
+	}
+	p := &protobufFormatter{
+		message:        message,
+		delimited:      cfg.Config.GetBool("protobuf.delimited"),
+		maxMessageSize: defaultProtobufMaxMessageSize,
+	}
+	if size := cfg.Config.GetString("protobuf.maxMessageSize"); size != "" {
+		max, err := configuration.ParseSize(size)
+		if err != nil {
+			return nil, fmt.Errorf("protobuf.maxMessageSize: %s", err.Error())
+		}
+		if max <= 0 {
+			return nil, fmt.Errorf("protobuf.maxMessageSize: %s is not a positive size", size)
+		}
+		p.maxMessageSize = max
+	}
+	return p, nil
+}
+
+func (p *protobufFormatter) FormatSingle(data []byte) (map[string]interface{}, error) {
+	m := dynamicpb.NewMessage(p.message)
+	if err := proto.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	return protoToMap(m)
+}
+
+func (p *protobufFormatter) FormatMultiple(data []byte) ([]interface{}, error) {
+	return formatMultiple(p, data)
+}
+
+// FormatStream calls fn with each message as it is read.
+func (p *protobufFormatter) FormatStream(r io.Reader, fn func(interface{}) error) error {
+	if !p.delimited {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		m, err := p.FormatSingle(data)
+		if err != nil {
+			return err
+		}
+		return fn(m)
+	}
+	reader := bufio.NewReader(r)
+	for {
+		m := dynamicpb.NewMessage(p.message)
+		err := protodelim.UnmarshalOptions{MaxSize: p.maxMessageSize}.UnmarshalFrom(reader, m)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		d, err := protoToMap(m)
+		if err != nil {
+			return err
+		}
+		if err := fn(d); err != nil {
+			return err
+		}
+	}
+}
+
+// protoToMap converts the message following the protobuf JSON mapping, with the
+// field names of the .proto file.
+func protoToMap(m proto.Message) (map[string]interface{}, error) {
+	b, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var d map[string]interface{}
+	return d, json.Unmarshal(b, &d)
+}
+
+type protobufEncoder struct {
+	message protoreflect.MessageDescriptor
+}
+
+// NewProtobufEncoder creates an encoder serializing JSON outputs, following the
+// protobuf JSON mapping, as protobuf.message messages.
+func NewProtobufEncoder(cfg *FormatterConfiguration) (Encoder, error) {
+	message, err := loadMessageDescriptor(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &protobufEncoder{message: message}, nil
+}
+
+func (p *protobufEncoder) Encode(data []byte) ([]byte, error) {
+	m := dynamicpb.NewMessage(p.message)
+	if err := protojson.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	return proto.Marshal(m)
+}
+
+func loadMessageDescriptor(cfg *FormatterConfiguration) (protoreflect.MessageDescriptor, error) {
+	file := cfg.Config.GetString("protobuf.descriptorSet")
+	if file == "" {
+		return nil, errors.New("protobuf.descriptorSet: is required")
+	}
+	name := cfg.Config.GetString("protobuf.message")
+	if name == "" {
+		return nil, errors.New("protobuf.message: is required")
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("protobuf.descriptorSet: %s", err.Error())
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("protobuf.descriptorSet: %s", err.Error())
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("protobuf.descriptorSet: %s", err.Error())
+	}
+	d, err := files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("protobuf.message: %s", err.Error())
+	}
+	message, isMessage := d.(protoreflect.MessageDescriptor)
+	if !isMessage {
+		return nil, fmt.Errorf("protobuf.message: %s is not a message", name)
+	}
+	return message, nil
+}
@@ -0,0 +1,21 @@
+package format
+
+import "net/url"
+
+// FormatValues converts url values into a document, fields with several values
+// being exposed as a list.
+func FormatValues(values url.Values) map[string]interface{} {
+	d := make(map[string]interface{}, len(values))
+	for key, vv := range values {
+		if len(vv) == 1 {
+			d[key] = vv[0]
+			continue
+		}
+		l := make([]interface{}, len(vv))
+		for i, v := range vv {
+			l[i] = v
+		}
+		d[key] = l
+	}
+	return d
+}
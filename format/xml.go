@@ -0,0 +1,223 @@
+package format
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	defaultAttributePrefix = "@"
+	defaultTextKey         = "#text"
+
+	// NamespacesStrip names elements and attributes by their local name.
+	NamespacesStrip = "strip"
+	// NamespacesPrefix keeps the prefix of the names as written, eg: soap:Envelope.
+	NamespacesPrefix = "prefix"
+	// NamespacesURI qualifies the names by their namespace URI, eg: {http://...}Envelope.
+	NamespacesURI = "uri"
+)
+
+type xmlFormatter struct {
+	attributePrefix string
+	textKey         string
+	namespaces      string
+	forceArray      map[string]bool
+	recordPath      []string
+}
+
+func init() {
+	Register("xml", NewXmlFormatter, "application/xml", "text/xml")
+}
+
+func NewXmlFormatter(cfg *FormatterConfiguration) (Formatter, error) {
+	x := &xmlFormatter{
+		attributePrefix: defaultAttributePrefix,
+		textKey:         defaultTextKey,
+		namespaces:      NamespacesStrip,
+		forceArray:      map[string]bool{},
+	}
+	if cfg.Config.Get("xml.attributePrefix") != nil {
+		x.attributePrefix = cfg.Config.GetString("xml.attributePrefix")
+	}
+	if textKey := cfg.Config.GetString("xml.textKey"); textKey != "" {
+		x.textKey = textKey
+	}
+	if namespaces := cfg.Config.GetString("xml.namespaces"); namespaces != "" {
+		switch namespaces {
+		case NamespacesStrip, NamespacesPrefix, NamespacesURI:
+		default:
+			return nil, fmt.Errorf("xml.namespaces: unsupported mode %s (strip, prefix or uri)", namespaces)
+		}
+		x.namespaces = namespaces
+	}
+	for _, name := range cfg.Config.GetStrings("xml.forceArray") {
+		x.forceArray[name] = true
+	}
+	if recordPath := strings.Trim(cfg.Config.GetString("xml.recordPath"), "/"); recordPath != "" {
+		x.recordPath = strings.Split(recordPath, "/")
+	}
+	return x, nil
+}
+
+func (x *xmlFormatter) FormatSingle(data []byte) (map[string]interface{}, error) {
+	dec := x.newDecoder(bytes.NewReader(data))
+	for {
+		t, err := x.token(dec)
+		if err == io.EOF {
+			return nil, errors.New("xml input has no root element")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if start, isStart := t.(xml.StartElement); isStart {
+			v, err := x.element(dec, start)
+			if err != nil {
+				return nil, err
+			}
+			return x.record(v), nil
+		}
+	}
+}
+
+func (x *xmlFormatter) FormatMultiple(data []byte) ([]interface{}, error) {
+	return formatMultiple(x, data)
+}
+
+// FormatStream calls fn with each record element as it is read. Records are the
+// elements at recordPath, or the children of the root element if it is not set.
+func (x *xmlFormatter) FormatStream(r io.Reader, fn func(interface{}) error) error {
+	dec := x.newDecoder(r)
+	var path []string
+	for {
+		t, err := x.token(dec)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch tok := t.(type) {
+		case xml.StartElement:
+			path = append(path, x.name(tok.Name))
+			if !x.isRecord(path) {
+				continue
+			}
+			v, err := x.element(dec, tok)
+			if err != nil {
+				return err
+			}
+			path = path[:len(path)-1]
+			if err := fn(x.record(v)); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+		}
+	}
+}
+
+func (x *xmlFormatter) isRecord(path []string) bool {
+	if x.recordPath == nil {
+		return len(path) == 2
+	}
+	if len(path) != len(x.recordPath) {
+		return false
+	}
+	for i := range path {
+		if path[i] != x.recordPath[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// element converts the element whose start has just been read. Attributes and
+// children become keys of a map, elements holding only text become strings.
+func (x *xmlFormatter) element(dec *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	m := map[string]interface{}{}
+	for _, attr := range start.Attr {
+		if x.namespaces != NamespacesPrefix && (attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns") {
+			continue
+		}
+		m[x.attributePrefix+x.name(attr.Name)] = attr.Value
+	}
+	var text strings.Builder
+	for {
+		t, err := x.token(dec)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		switch tok := t.(type) {
+		case xml.StartElement:
+			v, err := x.element(dec, tok)
+			if err != nil {
+				return nil, err
+			}
+			x.add(m, x.name(tok.Name), v)
+		case xml.CharData:
+			text.Write(tok)
+		case xml.EndElement:
+			s := strings.TrimSpace(text.String())
+			if len(m) == 0 {
+				return s, nil
+			}
+			if s != "" {
+				m[x.textKey] = s
+			}
+			return m, nil
+		}
+	}
+}
+
+// add sets a child element, repeated or forced-array elements are lists.
+func (x *xmlFormatter) add(m map[string]interface{}, name string, v interface{}) {
+	if _, exists := m[name]; !exists && x.forceArray[name] {
+		m[name] = []interface{}{v}
+		return
+	}
+	addValue(m, name, v)
+}
+
+// record returns the element as a map, wrapping the text of text only elements.
+func (x *xmlFormatter) record(v interface{}) map[string]interface{} {
+	if m, isMap := v.(map[string]interface{}); isMap {
+		return m
+	}
+	return map[string]interface{}{x.textKey: v}
+}
+
+func (x *xmlFormatter) name(name xml.Name) string {
+	if name.Space == "" || x.namespaces == NamespacesStrip {
+		return name.Local
+	}
+	if x.namespaces == NamespacesPrefix {
+		return name.Space + ":" + name.Local
+	}
+	return "{" + name.Space + "}" + name.Local
+}
+
+func (x *xmlFormatter) newDecoder(r io.Reader) *xml.Decoder {
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = charset.NewReaderLabel
+	return dec
+}
+
+// token returns the next token, with the prefixes of the names as written when
+// namespaces are kept as prefixes.
+func (x *xmlFormatter) token(dec *xml.Decoder) (xml.Token, error) {
+	if x.namespaces == NamespacesPrefix {
+		return dec.RawToken()
+	}
+	return dec.Token()
+}
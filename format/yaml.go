@@ -1,19 +1,74 @@
 package format
 
-import "gopkg.in/yaml.v2"
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"gopkg.in/yaml.v2"
+)
 
 type yamlFormatter struct{}
 
+func init() {
+	Register("yaml", NewYamlFormatter, "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml")
+}
+
 func NewYamlFormatter(cfg *FormatterConfiguration) (Formatter, error) {
 	return &yamlFormatter{}, nil
 }
 
 func (y *yamlFormatter) FormatSingle(data []byte) (map[string]interface{}, error) {
 	var d map[string]interface{}
-	return d, yaml.Unmarshal(data, &d)
+	err := y.documents(bytes.NewReader(data), func(doc interface{}) error {
+		if d != nil {
+			return errors.New("yaml input has several documents")
+		}
+		m, err := normalizedMap(doc)
+		if err != nil {
+			return err
+		}
+		d = m
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, errors.New("yaml input has no document")
+	}
+	return d, nil
 }
 
 func (y *yamlFormatter) FormatMultiple(data []byte) ([]interface{}, error) {
-	var d []interface{}
-	return d, yaml.Unmarshal(data, &d)
+	return formatMultiple(y, data)
+}
+
+// FormatStream calls fn with each document of the YAML stream, the elements of
+// documents which are lists being read as documents.
+func (y *yamlFormatter) FormatStream(r io.Reader, fn func(interface{}) error) error {
+	return y.documents(r, func(doc interface{}) error {
+		return eachElement(doc, fn)
+	})
+}
+
+// documents calls fn with each non empty document of the YAML stream.
+func (y *yamlFormatter) documents(r io.Reader, fn func(interface{}) error) error {
+	dec := yaml.NewDecoder(r)
+	for {
+		var doc interface{}
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if doc == nil {
+			continue
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
 }
@@ -0,0 +1,171 @@
+package format
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/skilld-labs/http-event-adapter/configuration"
+	"github.com/skilld-labs/http-event-adapter/log"
+)
+
+func newTestFormatter(t *testing.T, factory Factory) Formatter {
+	t.Helper()
+	logger := log.NewJsonLogger(&log.LoggerConfiguration{Verbosity: log.Fatal})
+	cfg, err := configuration.NewKoanfProvider(configuration.ProviderConfig{Logger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := factory(&FormatterConfiguration{Logger: logger, Config: cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// assertSameDocument fails if the documents have different JSON encodings, or if the
+// yaml one holds maps which are not keyed by strings.
+func assertSameDocument(t *testing.T, yamlDoc, jsonDoc interface{}) {
+	t.Helper()
+	assertStringKeys(t, yamlDoc)
+	y, err := json.Marshal(yamlDoc)
+	if err != nil {
+		t.Fatalf("yaml document cannot be encoded in JSON: %s", err.Error())
+	}
+	j, err := json.Marshal(jsonDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(y) != string(j) {
+		t.Errorf("yaml document %s, json document %s", y, j)
+	}
+}
+
+func assertStringKeys(t *testing.T, v interface{}) {
+	t.Helper()
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		t.Errorf("map with non string keys: %v", value)
+	case map[string]interface{}:
+		for _, item := range value {
+			assertStringKeys(t, item)
+		}
+	case []interface{}:
+		for _, item := range value {
+			assertStringKeys(t, item)
+		}
+	}
+}
+
+func TestYamlFormatSingleMatchesJson(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		json string
+	}{
+		{
+			name: "flat map",
+			yaml: "name: alice\nage: 42\nactive: true\nscore: 1.5\nnote: null\n",
+			json: `{"name":"alice","age":42,"active":true,"score":1.5,"note":null}`,
+		},
+		{
+			name: "nested maps",
+			yaml: "customer:\n  address:\n    city: Paris\n    zip: \"75001\"\n",
+			json: `{"customer":{"address":{"city":"Paris","zip":"75001"}}}`,
+		},
+		{
+			name: "lists of maps",
+			yaml: "lines:\n  - sku: a\n    qty: 1\n  - sku: b\n    tags: [x, z]\n",
+			json: `{"lines":[{"sku":"a","qty":1},{"sku":"b","tags":["x","z"]}]}`,
+		},
+		{
+			name: "non string keys",
+			yaml: "1: one\ntrue: yes\nnested:\n  2: two\n",
+			json: `{"1":"one","true":true,"nested":{"2":"two"}}`,
+		},
+		{
+			name: "explicit document start",
+			yaml: "---\nid: 1\n",
+			json: `{"id":1}`,
+		},
+	}
+	y := newTestFormatter(t, NewYamlFormatter)
+	j := newTestFormatter(t, NewJsonFormatter)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			yamlDoc, err := y.FormatSingle([]byte(tt.yaml))
+			if err != nil {
+				t.Fatalf("yaml: %s", err.Error())
+			}
+			jsonDoc, err := j.FormatSingle([]byte(tt.json))
+			if err != nil {
+				t.Fatalf("json: %s", err.Error())
+			}
+			assertSameDocument(t, yamlDoc, jsonDoc)
+		})
+	}
+}
+
+func TestYamlFormatMultipleMatchesJson(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		json string
+	}{
+		{
+			name: "list document",
+			yaml: "- id: 1\n  tags: [a]\n- id: 2\n  owner: {name: bob}\n",
+			json: `[{"id":1,"tags":["a"]},{"id":2,"owner":{"name":"bob"}}]`,
+		},
+		{
+			name: "several documents",
+			yaml: "id: 1\n---\nid: 2\nnested:\n  3: three\n",
+			json: `[{"id":1},{"id":2,"nested":{"3":"three"}}]`,
+		},
+		{
+			name: "documents holding lists",
+			yaml: "- id: 1\n- id: 2\n---\n- id: 3\n",
+			json: `[{"id":1},{"id":2},{"id":3}]`,
+		},
+		{
+			name: "empty documents are skipped",
+			yaml: "---\n---\nid: 1\n---\n",
+			json: `[{"id":1}]`,
+		},
+	}
+	y := newTestFormatter(t, NewYamlFormatter)
+	j := newTestFormatter(t, NewJsonFormatter)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			yamlDocs, err := y.FormatMultiple([]byte(tt.yaml))
+			if err != nil {
+				t.Fatalf("yaml: %s", err.Error())
+			}
+			jsonDocs, err := j.FormatMultiple([]byte(tt.json))
+			if err != nil {
+				t.Fatalf("json: %s", err.Error())
+			}
+			assertSameDocument(t, yamlDocs, jsonDocs)
+		})
+	}
+}
+
+func TestYamlFormatSingleErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+	}{
+		{"several documents", "id: 1\n---\nid: 2\n"},
+		{"no document", "---\n"},
+		{"list", "- id: 1\n"},
+		{"scalar", "hello\n"},
+		{"invalid", "id: [1\n"},
+	}
+	y := newTestFormatter(t, NewYamlFormatter)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if d, err := y.FormatSingle([]byte(tt.yaml)); err == nil {
+				t.Errorf("expected an error, got %v", d)
+			}
+		})
+	}
+}
@@ -3,22 +3,34 @@ module github.com/skilld-labs/http-event-adapter
 go 1.22.0
 
 require (
+	github.com/andybalholm/brotli v1.1.0
+	github.com/expr-lang/expr v1.17.6
+	github.com/fsnotify/fsnotify v1.4.9
+	github.com/fxamacker/cbor/v2 v2.7.0
+	github.com/klauspost/compress v1.17.2
 	github.com/knadh/koanf v1.5.0
+	github.com/linkedin/goavro/v2 v2.12.0
+	github.com/mitchellh/mapstructure v1.5.0
 	github.com/nats-io/nats.go v1.33.1
-	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+	golang.org/x/crypto v0.19.0
+	golang.org/x/net v0.21.0
+	golang.org/x/sync v0.6.0
+	google.golang.org/protobuf v1.34.2
 	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
-	github.com/fsnotify/fsnotify v1.4.9 // indirect
-	github.com/klauspost/compress v1.17.2 // indirect
+	github.com/golang/snappy v0.0.1 // indirect
 	github.com/mitchellh/copystructure v1.2.0 // indirect
-	github.com/mitchellh/mapstructure v1.5.0 // indirect
 	github.com/mitchellh/reflectwalk v1.0.2 // indirect
 	github.com/nats-io/nkeys v0.4.7 // indirect
 	github.com/nats-io/nuid v1.0.1 // indirect
-	golang.org/x/crypto v0.18.0 // indirect
-	golang.org/x/sys v0.16.0 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+	golang.org/x/sys v0.17.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
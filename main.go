@@ -3,70 +3,162 @@ package main
 import (
 	"bytes"
 	"flag"
-	"io/ioutil"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/skilld-labs/http-event-adapter/auth"
 	"github.com/skilld-labs/http-event-adapter/configuration"
+	"github.com/skilld-labs/http-event-adapter/extension"
 	"github.com/skilld-labs/http-event-adapter/log"
 
 	"github.com/skilld-labs/http-event-adapter/adapter"
 	"github.com/skilld-labs/http-event-adapter/format"
 	"github.com/skilld-labs/http-event-adapter/router"
+	"github.com/skilld-labs/http-event-adapter/server"
 	"github.com/skilld-labs/http-event-adapter/writer"
 )
 
 func main() {
-	config := flag.String("config", "config.yaml", "The path for the config file")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate":
+			os.Exit(validateCommand(os.Args[2:]))
+		case "config":
+			os.Exit(configCommand(os.Args[2:]))
+		}
+	}
+	options := addConfigFlags(flag.CommandLine)
 	flag.Parse()
 
 	l := log.NewJsonLogger(&log.LoggerConfiguration{})
-	cfg, err := configuration.NewKoanfProvider(configuration.ProviderConfig{
-		Logger: l,
-		Source: *config,
-	})
+	cfg, err := options.load(l)
 	if err != nil {
 		l.Fatal(err.Error())
 	}
 	l.SetVerbosity(cfg.GetString("log.verbosity"))
 
-	r := router.NewRouter(&router.RouterConfiguration{Logger: l})
+	maxBodySize, err := configuration.ParseSize(cfg.GetString("maxBodySize"))
+	if err != nil {
+		l.Fatal("maxBodySize: %s", err.Error())
+	}
+	r := router.NewRouter(&router.RouterConfiguration{Logger: l, MaxBodySize: maxBodySize})
+
+	routes, files, err := newRoutes(l, cfg)
+	if err != nil {
+		if errs, ok := err.(configurationErrors); ok {
+			for _, e := range errs {
+				l.Err(e.Error())
+			}
+			l.Fatal("invalid configuration ... exiting")
+		}
+		l.Fatal(err.Error())
+	}
+	r.SetRoutes(routes)
+	commitStaged()
 
-	formatterCfg := &format.FormatterConfiguration{Logger: l, Config: cfg}
+	rl, err := newReloader(l, options, r)
+	if err != nil {
+		l.Fatal(err.Error())
+	}
+	rl.watch(files)
+	go rl.run()
+
+	srv, err := server.NewServer(&server.ServerConfiguration{
+		Logger:  l,
+		Config:  cfg,
+		Handler: debugMiddleware(l, cfg.GetBool("debug"), cfg.GetString("debugDirectory"), r),
+	})
+	if err != nil {
+		l.Fatal(err.Error())
+	}
+	l.Fatal(srv.ListenAndServe().Error())
+}
+
+func newAdapter(l log.Logger, cfg configuration.Provider) (*adapter.Adapter, error) {
 	writerCfg := &writer.WriterConfiguration{Logger: l, Config: cfg}
-	a, err := adapter.NewAdapter(&adapter.AdapterConfiguration{
+	return adapter.NewAdapter(&adapter.AdapterConfiguration{
 		Logger: l,
 		Config: cfg,
-		FormatterByName: func(name string) (format.Formatter, error) {
-			return format.GetFormatter(formatterCfg, name)
+		FormatterByName: func(name string, formatCfg configuration.Provider) (format.Formatter, error) {
+			return format.GetFormatter(&format.FormatterConfiguration{Logger: l, Config: formatCfg}, name)
 		},
 		WriterByName: func(name string) (writer.Writer, error) {
 			return writer.GetWriter(writerCfg, name)
 		},
 	})
+}
+
+// commitStaged applies the writer connections staged while building the routes and
+// stops the extensions they no longer use, once the routes are in place.
+func commitStaged() {
+	writer.Commit()
+	extension.Commit()
+}
+
+// discardStaged drops the writer connections and extensions started while building
+// routes which cannot be used.
+func discardStaged() {
+	writer.Discard()
+	extension.Discard()
+}
+
+// newRoutes validates the configuration and builds the routes it describes, it also
+// returns the files used by those routes so they can be watched.
+func newRoutes(l log.Logger, cfg configuration.Provider) (map[string]*router.Route, []string, error) {
+	if errs := validateConfiguration(l, cfg); len(errs) > 0 {
+		return nil, nil, errs
+	}
+	a, err := newAdapter(l, cfg)
 	if err != nil {
-		l.Fatal(err.Error())
+		return nil, nil, err
+	}
+	var globalAuth *auth.Configuration
+	if err := cfg.Load("auth", &globalAuth); err != nil {
+		return nil, nil, err
 	}
 	events := make(map[string]*adapter.EventConfiguration)
-	cfg.Load("events", &events)
-	if len(events) == 0 {
-		l.Fatal("no event configuration ... exiting")
+	if err := cfg.Load("events", &events); err != nil {
+		return nil, nil, err
 	}
+	routes := make(map[string]*router.Route, len(events))
+	// rotated secret files are used once the configuration is reloaded
+	files := cfg.SecretFiles()
 	for path, event := range events {
 		c, err := a.AdaptEvent(event)
 		if err != nil {
-			l.Fatal(err.Error())
+			return nil, nil, fmt.Errorf("%s: %s", path, err.Error())
+		}
+		authCfg := event.Auth
+		if authCfg == nil {
+			authCfg = globalAuth
+		}
+		if event.Stream && authCfg != nil && authCfg.HMAC != nil {
+			return nil, nil, fmt.Errorf("%s: stream cannot be used with hmac authentication, which needs the whole body", path)
+		}
+		authenticator, err := auth.NewAuthenticator(authCfg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %s", path, err.Error())
+		}
+		maxBodySize, err := configuration.ParseSize(event.MaxBodySize)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: maxBodySize: %s", path, err.Error())
+		}
+		routes[path] = &router.Route{Methods: event.Methods, MaxBodySize: maxBodySize, Authenticator: authenticator, Sync: event.Sync, Stream: event.Stream, Callback: c}
+		files = append(files, event.OutputTemplate)
+		for _, schemaFile := range []string{event.InputSchema, event.OutputSchema} {
+			if schemaFile != "" {
+				files = append(files, schemaFile)
+			}
+		}
+		if event.Transform != nil && event.Transform.File != "" {
+			files = append(files, event.Transform.File)
 		}
-		r.AddRoute(path, c)
 	}
-
-	http.Handle("/", debugMiddleware(l, cfg.GetBool("debug"), cfg.GetString("debugDirectory"), r))
-
-	port := cfg.GetString("port")
-	l.Info("server listening on %s", port)
-	l.Fatal(http.ListenAndServe(":"+port, nil).Error())
+	return routes, files, nil
 }
 
 func debugMiddleware(logger log.Logger, debug bool, debugDirectory string, handler http.Handler) http.Handler {
@@ -82,29 +174,40 @@ func debugMiddleware(logger log.Logger, debug bool, debugDirectory string, handl
 	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		// Read the body data
-		body, err := ioutil.ReadAll(req.Body)
-		if err != nil {
-			http.Error(w, "Error reading request body", http.StatusInternalServerError)
-			return
-		}
+		// Copy the body in memory while the original handler reads it, so that the
+		// route body size limit applies
+		var body bytes.Buffer
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.TeeReader(req.Body, &body), req.Body}
+		rw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
 
-		// Restore the body so it can be read by the original handler
-		req.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+		// Call the original handler
+		handler.ServeHTTP(rw, req)
 
+		// Requests without body or which do not match any route are not kept
+		if body.Len() == 0 || rw.status == http.StatusNotFound || rw.status == http.StatusMethodNotAllowed {
+			return
+		}
 		// Generate a filename based on the current time to avoid overwrites
 		timestamp := time.Now().Format("20060102150405.999999")
 		fileName := filepath.Join(debugDirectory, timestamp+"_request.txt")
-
-		// Write the body to a file
-		err = ioutil.WriteFile(fileName, body, 0644)
-		if err != nil {
-			http.Error(w, "Error writing request to debug file", http.StatusInternalServerError)
+		if err := os.WriteFile(fileName, body.Bytes(), 0644); err != nil {
+			logger.Err("cannot write request to debug file %s (err : %s)", fileName, err.Error())
 			return
 		}
 		logger.Debug("file %s has been created for debug purpose", fileName)
-
-		// Call the original handler
-		handler.ServeHTTP(w, req)
 	})
 }
+
+// statusResponseWriter records the status of the response.
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
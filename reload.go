@@ -0,0 +1,166 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/skilld-labs/http-event-adapter/configuration"
+	"github.com/skilld-labs/http-event-adapter/log"
+	"github.com/skilld-labs/http-event-adapter/router"
+)
+
+const (
+	reloadDelay = 500 * time.Millisecond
+)
+
+// reloader rebuilds the routes when the config file or one of the files used by the
+// routes changes, or when SIGHUP is received. Invalid configurations are logged and
+// the current routes are kept.
+type reloader struct {
+	logger  log.Logger
+	options *configOptions
+	router  *router.Router
+	watcher *fsnotify.Watcher
+
+	mu    sync.Mutex
+	files map[string]bool
+	dirs  map[string]bool
+}
+
+func newReloader(logger log.Logger, options *configOptions, r *router.Router) (*reloader, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	return &reloader{
+		logger:  logger,
+		options: options,
+		router:  r,
+		watcher: watcher,
+		files:   make(map[string]bool),
+		dirs:    make(map[string]bool),
+	}, nil
+}
+
+// watch replaces the watched files by the config files and the given files.
+func (rl *reloader) watch(files []string) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.files = make(map[string]bool)
+	files = append([]string{rl.options.file}, files...)
+	if rl.options.directory != "" {
+		// new fragments are detected by watching the directory itself
+		rl.watchDir(rl.options.directory)
+	}
+	for _, f := range files {
+		if f == "" {
+			continue
+		}
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			rl.logger.Err("cannot watch %s (err : %s)", f, err.Error())
+			continue
+		}
+		rl.files[abs] = true
+		// directories are watched so that files replaced by a rename are still watched
+		rl.watchDir(filepath.Dir(abs))
+	}
+}
+
+func (rl *reloader) watchDir(dir string) {
+	abs, err := filepath.Abs(dir)
+	if err != nil || rl.dirs[abs] {
+		return
+	}
+	if err := rl.watcher.Add(abs); err != nil {
+		rl.logger.Err("cannot watch %s (err : %s)", abs, err.Error())
+		return
+	}
+	rl.dirs[abs] = true
+}
+
+func (rl *reloader) run() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	var timer *time.Timer
+	reload := make(chan struct{}, 1)
+	for {
+		select {
+		case <-signals:
+			rl.logger.Info("SIGHUP received, reloading configuration")
+			rl.reload()
+		case event, ok := <-rl.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 || !rl.isWatched(event.Name) {
+				continue
+			}
+			rl.logger.Debug("%s has changed, reloading configuration", event.Name)
+			// editors and deployment tools often write files in several steps
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(reloadDelay, func() {
+				select {
+				case reload <- struct{}{}:
+				default:
+				}
+			})
+		case <-reload:
+			rl.reload()
+		case err, ok := <-rl.watcher.Errors:
+			if !ok {
+				return
+			}
+			rl.logger.Err("error while watching configuration files (err : %s)", err.Error())
+		}
+	}
+}
+
+func (rl *reloader) isWatched(name string) bool {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return false
+	}
+	if rl.options.directory != "" {
+		if dir, err := filepath.Abs(rl.options.directory); err == nil && filepath.Dir(abs) == dir {
+			if ext := filepath.Ext(abs); ext == ".yaml" || ext == ".yml" {
+				return true
+			}
+		}
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.files[abs]
+}
+
+func (rl *reloader) reload() {
+	cfg, err := rl.options.load(rl.logger)
+	if err != nil {
+		rl.logger.Err("error while reloading configuration, keeping the current one (err : %s)", err.Error())
+		return
+	}
+	maxBodySize, err := configuration.ParseSize(cfg.GetString("maxBodySize"))
+	if err != nil {
+		rl.logger.Err("error while reloading configuration, keeping the current one (err : maxBodySize: %s)", err.Error())
+		return
+	}
+	routes, files, err := newRoutes(rl.logger, cfg)
+	if err != nil {
+		discardStaged()
+		rl.logger.Err("error while reloading configuration, keeping the current one (err : %s)", err.Error())
+		return
+	}
+	rl.router.SetMaxBodySize(maxBodySize)
+	rl.router.SetRoutes(routes)
+	commitStaged()
+	rl.watch(files)
+	rl.logger.Info("configuration has been reloaded (%d routes)", len(routes))
+}
@@ -0,0 +1,84 @@
+package router
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+type errUnsupportedEncoding string
+
+func (e errUnsupportedEncoding) Error() string {
+	return fmt.Sprintf("unsupported Content-Encoding %s", string(e))
+}
+
+// decodeBody returns a reader decoding the body according to its Content-Encoding.
+func decodeBody(body io.ReadCloser, contentEncoding string) (io.ReadCloser, error) {
+	encodings := strings.Split(contentEncoding, ",")
+	// encodings are listed in the order they have been applied
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var err error
+		switch encoding := strings.ToLower(strings.TrimSpace(encodings[i])); encoding {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			body, err = wrapReader(body, func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) })
+		case "deflate":
+			body, err = wrapReader(body, newDeflateReader)
+		case "zstd":
+			body, err = wrapReader(body, func(r io.Reader) (io.ReadCloser, error) {
+				d, err := zstd.NewReader(r)
+				if err != nil {
+					return nil, err
+				}
+				return d.IOReadCloser(), nil
+			})
+		case "br":
+			body, err = wrapReader(body, func(r io.Reader) (io.ReadCloser, error) { return io.NopCloser(brotli.NewReader(r)), nil })
+		default:
+			return nil, errUnsupportedEncoding(encoding)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+type decodedBody struct {
+	io.ReadCloser
+	source io.Closer
+}
+
+func (d *decodedBody) Close() error {
+	err := d.ReadCloser.Close()
+	if sourceErr := d.source.Close(); err == nil {
+		err = sourceErr
+	}
+	return err
+}
+
+func wrapReader(body io.ReadCloser, decoder func(io.Reader) (io.ReadCloser, error)) (io.ReadCloser, error) {
+	d, err := decoder(body)
+	if err != nil {
+		return nil, err
+	}
+	return &decodedBody{ReadCloser: d, source: body}, nil
+}
+
+// newDeflateReader accepts both zlib streams, as specified by RFC 9110, and the raw
+// deflate streams sent by some clients.
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
@@ -2,53 +2,316 @@ package router
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 
+	"github.com/skilld-labs/http-event-adapter/auth"
 	"github.com/skilld-labs/http-event-adapter/log"
 )
 
+var defaultMethods = []string{http.MethodPost}
+
 type RouterConfiguration struct {
 	Logger log.Logger
+	// MaxBodySize is the body size limit of routes without their own, 0 means no limit.
+	MaxBodySize int64
 }
 
 type Router struct {
-	logger log.Logger
-	routes map[string]func([]byte) error
+	logger      log.Logger
+	maxBodySize int64
+	mu          sync.RWMutex
+	routes      map[string]*Route
+}
+
+// Request holds what a route callback needs from the incoming http request.
+type Request struct {
+	Method      string
+	ContentType string
+	Query       url.Values
+	Body        []byte
+	// Reader streams the body of the requests on Stream routes, Body is then nil.
+	Reader    io.Reader
+	Principal *auth.Principal
+}
+
+type Route struct {
+	// Methods lists the allowed http methods, POST only if empty.
+	Methods []string
+	// MaxBodySize limits the size of the body, before and after decoding.
+	MaxBodySize int64
+	// Authenticator, if set, rejects unauthorized requests before the callback runs.
+	Authenticator auth.Authenticator
+	// Sync runs the callback before answering, its error is reported to the client.
+	Sync bool
+	// Stream runs the callback before answering with the body being read as the
+	// callback consumes it. Authenticators must not need the body.
+	Stream   bool
+	Callback func(*Request) error
+}
+
+// Error is returned by callbacks to answer synchronous requests with a given status.
+type Error struct {
+	Status int
+	Err    error
+	// Details, if set, is encoded in JSON as the response body.
+	Details interface{}
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
 }
 
 func NewRouter(cfg *RouterConfiguration) *Router {
-	return &Router{logger: cfg.Logger, routes: make(map[string]func([]byte) error)}
+	return &Router{logger: cfg.Logger, maxBodySize: cfg.MaxBodySize, routes: make(map[string]*Route)}
 }
 
-func (r *Router) AddRoute(path string, callback func([]byte) error) error {
+func (r *Router) AddRoute(path string, route *Route) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if _, exists := r.routes[path]; exists {
 		return fmt.Errorf("a route have been already registered on route %s", path)
 	}
-	r.routes[path] = callback
-	r.logger.Debug("added new route on path %s", path)
+	r.routes[path] = r.initRoute(path, route)
 	return nil
 }
 
+// SetRoutes atomically replaces all the routes, requests being processed keep
+// running with the previous ones.
+func (r *Router) SetRoutes(routes map[string]*Route) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	table := make(map[string]*Route, len(routes))
+	for path, route := range routes {
+		table[path] = r.initRoute(path, route)
+	}
+	r.routes = table
+}
+
+// SetMaxBodySize replaces the body size limit of routes without their own, it applies
+// to the routes added afterwards.
+func (r *Router) SetMaxBodySize(size int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.maxBodySize = size
+}
+
+func (r *Router) initRoute(path string, route *Route) *Route {
+	if len(route.Methods) == 0 {
+		route.Methods = defaultMethods
+	}
+	methods := make([]string, len(route.Methods))
+	for i, m := range route.Methods {
+		methods[i] = strings.ToUpper(m)
+	}
+	route.Methods = methods
+	if route.MaxBodySize == 0 {
+		route.MaxBodySize = r.maxBodySize
+	}
+	r.logger.Debug("added new route on path %s (methods: %s)", path, strings.Join(route.Methods, ", "))
+	return route
+}
+
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	r.logger.Debug("received a new request on %s", req.URL.Path)
-	if req.Method != "POST" {
+	r.mu.RLock()
+	route, exists := r.routes[req.URL.Path]
+	r.mu.RUnlock()
+	if !exists {
+		r.logger.Err("no route on path %s", req.URL.Path)
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if !route.allows(req.Method) {
+		w.Header().Set("Allow", strings.Join(route.Methods, ", "))
 		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
-		r.logger.Err("method %s is not allowed", req.Method)
+		r.logger.Err("method %s is not allowed on path %s", req.Method, req.URL.Path)
 		return
 	}
-	if callback, exists := r.routes[req.URL.Path]; exists {
-		body := new(bytes.Buffer)
-		body.ReadFrom(req.Body)
-		go func() {
-			if err := callback(body.Bytes()); err != nil {
-				http.Error(w, http.StatusText(http.StatusBadRequest)+" : "+err.Error(), http.StatusBadRequest)
-				r.logger.Err("error while running callback function of %s path (err : %s)", req.URL.Path, err.Error())
-			}
-		}()
-	} else {
-		r.logger.Err("no route on path %s", req.URL.Path)
-		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	if route.Stream {
+		r.serveStream(w, req, route)
+		return
+	}
+	raw, err := r.readBody(w, req, route)
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		r.logger.Err("error while reading request body on path %s (err : %s)", req.URL.Path, err.Error())
+		return
+	}
+	// signatures are computed by the sender over the body as it is sent
+	principal, ok := r.authenticate(w, req, route, raw)
+	if !ok {
+		return
+	}
+	body, err := r.decodeBody(w, req, route, raw)
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		r.logger.Err("error while decoding request body on path %s (err : %s)", req.URL.Path, err.Error())
+		return
+	}
+	request := &Request{
+		Method:      req.Method,
+		ContentType: req.Header.Get("Content-Type"),
+		Query:       req.URL.Query(),
+		Body:        body,
+		Principal:   principal,
+	}
+	if route.Sync {
+		if err := route.Callback(request); err != nil {
+			r.logger.Err("error while running callback function of %s path (err : %s)", req.URL.Path, err.Error())
+			writeError(w, err, http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, http.StatusText(http.StatusOK))
+		return
 	}
+	go func() {
+		if err := route.Callback(request); err != nil {
+			r.logger.Err("error while running callback function of %s path (err : %s)", req.URL.Path, err.Error())
+		}
+	}()
 	fmt.Fprint(w, http.StatusText(http.StatusAccepted))
 }
+
+// serveStream runs the callback of a Stream route while the body is being read.
+func (r *Router) serveStream(w http.ResponseWriter, req *http.Request, route *Route) {
+	body, err := r.bodyReader(w, req, route)
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		r.logger.Err("error while reading request body on path %s (err : %s)", req.URL.Path, err.Error())
+		return
+	}
+	defer body.Close()
+	principal, ok := r.authenticate(w, req, route, nil)
+	if !ok {
+		return
+	}
+	request := &Request{
+		Method:      req.Method,
+		ContentType: req.Header.Get("Content-Type"),
+		Query:       req.URL.Query(),
+		Reader:      body,
+		Principal:   principal,
+	}
+	if err := route.Callback(request); err != nil {
+		r.logger.Err("error while running callback function of %s path (err : %s)", req.URL.Path, err.Error())
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, http.StatusText(http.StatusOK))
+}
+
+// authenticate runs the authenticator of the route, answering the request if it is
+// rejected.
+func (r *Router) authenticate(w http.ResponseWriter, req *http.Request, route *Route, body []byte) (*auth.Principal, bool) {
+	if route.Authenticator == nil {
+		return nil, true
+	}
+	p, err := route.Authenticator.Authenticate(req, body)
+	if err != nil {
+		if c, ok := route.Authenticator.(auth.Challenger); ok {
+			for _, challenge := range c.Challenges() {
+				w.Header().Add("WWW-Authenticate", challenge)
+			}
+		}
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		r.logger.Err("request on path %s rejected (err : %s)", req.URL.Path, err.Error())
+		return nil, false
+	}
+	return p, true
+}
+
+// writeError answers a request which failed, with the given status unless the
+// error tells otherwise.
+func writeError(w http.ResponseWriter, err error, status int) {
+	var tooLarge *http.MaxBytesError
+	var unsupported errUnsupportedEncoding
+	var e *Error
+	switch {
+	case errors.As(err, &tooLarge):
+		status = http.StatusRequestEntityTooLarge
+	case errors.As(err, &unsupported):
+		status = http.StatusUnsupportedMediaType
+	case errors.As(err, &e) && e.Details != nil:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(e.Status)
+		json.NewEncoder(w).Encode(e.Details)
+		return
+	case errors.As(err, &e):
+		http.Error(w, e.Error(), e.Status)
+		return
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+// readBody reads the body of the request as it is sent, limited by the MaxBodySize
+// of the route.
+func (r *Router) readBody(w http.ResponseWriter, req *http.Request, route *Route) ([]byte, error) {
+	reader := req.Body
+	if route.MaxBodySize > 0 {
+		reader = http.MaxBytesReader(w, reader, route.MaxBodySize)
+	}
+	body := new(bytes.Buffer)
+	if _, err := body.ReadFrom(reader); err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
+
+// decodeBody decodes a body read by readBody according to its Content-Encoding, the
+// decoded body being also limited by the MaxBodySize of the route.
+func (r *Router) decodeBody(w http.ResponseWriter, req *http.Request, route *Route, raw []byte) ([]byte, error) {
+	contentEncoding := req.Header.Get("Content-Encoding")
+	if contentEncoding == "" {
+		return raw, nil
+	}
+	reader, err := decodeBody(io.NopCloser(bytes.NewReader(raw)), contentEncoding)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	if route.MaxBodySize > 0 {
+		reader = http.MaxBytesReader(w, reader, route.MaxBodySize)
+	}
+	body := new(bytes.Buffer)
+	if _, err := body.ReadFrom(reader); err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
+
+// bodyReader returns the decoded body of the request, limited before and after
+// decoding by the MaxBodySize of the route.
+func (r *Router) bodyReader(w http.ResponseWriter, req *http.Request, route *Route) (io.ReadCloser, error) {
+	reader := req.Body
+	if route.MaxBodySize > 0 {
+		reader = http.MaxBytesReader(w, reader, route.MaxBodySize)
+	}
+	reader, err := decodeBody(reader, req.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, err
+	}
+	if route.MaxBodySize > 0 {
+		reader = http.MaxBytesReader(w, reader, route.MaxBodySize)
+	}
+	return reader, nil
+}
+
+func (r *Route) allows(method string) bool {
+	for _, m := range r.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/skilld-labs/http-event-adapter/format"
+)
+
+// Schema validates documents against a JSON Schema.
+type Schema struct {
+	schema *jsonschema.Schema
+}
+
+// Violation is a part of a document which does not match the schema.
+type Violation struct {
+	// Pointer is the JSON pointer of the invalid value in the document.
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	pointer := v.Pointer
+	if pointer == "" {
+		pointer = "/"
+	}
+	return fmt.Sprintf("%s: %s", pointer, v.Message)
+}
+
+// Load compiles the JSON Schema file.
+func Load(path string) (*Schema, error) {
+	s, err := jsonschema.Compile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{schema: s}, nil
+}
+
+// Validate checks a document, as returned by the formatters, against the schema.
+func (s *Schema) Validate(v interface{}) ([]Violation, error) {
+	b, err := json.Marshal(format.Normalize(v))
+	if err != nil {
+		return nil, err
+	}
+	return s.ValidateJSON(b)
+}
+
+// ValidateJSON checks a JSON encoded document against the schema.
+func (s *Schema) ValidateJSON(b []byte) ([]Violation, error) {
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return []Violation{{Message: "invalid JSON: " + err.Error()}}, nil
+	}
+	err := s.schema.Validate(doc)
+	var verr *jsonschema.ValidationError
+	if errors.As(err, &verr) {
+		return violations(verr, nil), nil
+	}
+	return nil, err
+}
+
+// violations returns the leaves of the validation error tree, which are the most
+// precise causes.
+func violations(err *jsonschema.ValidationError, vv []Violation) []Violation {
+	if len(err.Causes) == 0 {
+		return append(vv, Violation{Pointer: err.InstanceLocation, Message: err.Message})
+	}
+	for _, cause := range err.Causes {
+		vv = append(vv, violations(cause, nil)...)
+	}
+	return vv
+}
+
+// Error returns an error listing the violations.
+func Error(vv []Violation) error {
+	messages := make([]string, len(vv))
+	for i, v := range vv {
+		messages[i] = v.String()
+	}
+	return errors.New(strings.Join(messages, ", "))
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"github.com/skilld-labs/http-event-adapter/configuration"
+	"github.com/skilld-labs/http-event-adapter/log"
+)
+
+const (
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultIdleTimeout       = 2 * time.Minute
+)
+
+type ServerConfiguration struct {
+	Logger  log.Logger
+	Config  configuration.Provider
+	Handler http.Handler
+}
+
+// Configuration is loaded from the server key, durations use the time.ParseDuration
+// format and an empty duration disables the timeout unless it has a default value.
+type Configuration struct {
+	Address           string            `config:"address"`
+	ReadTimeout       string            `config:"readTimeout"`
+	ReadHeaderTimeout string            `config:"readHeaderTimeout"`
+	WriteTimeout      string            `config:"writeTimeout"`
+	IdleTimeout       string            `config:"idleTimeout"`
+	MaxHeaderBytes    int               `config:"maxHeaderBytes"`
+	H2C               bool              `config:"h2c"`
+	TLS               *TLSConfiguration `config:"tls"`
+	// MetricsPath serves the expvar variables (eg: /debug/vars), without
+	// authentication, when it is set.
+	MetricsPath string `config:"metricsPath"`
+}
+
+type Server struct {
+	logger log.Logger
+	server *http.Server
+	tls    bool
+}
+
+func NewServer(cfg *ServerConfiguration) (*Server, error) {
+	var c Configuration
+	if err := cfg.Config.Load("server", &c); err != nil {
+		return nil, err
+	}
+	if c.Address == "" {
+		c.Address = ":" + cfg.Config.GetString("port")
+	}
+	handler := cfg.Handler
+	if c.MetricsPath != "" {
+		if !strings.HasPrefix(c.MetricsPath, "/") {
+			return nil, fmt.Errorf("server.metricsPath: %s does not start with /", c.MetricsPath)
+		}
+		handler = withMetrics(c.MetricsPath, handler)
+	}
+	s := &Server{
+		logger: cfg.Logger,
+		server: &http.Server{
+			Addr:              c.Address,
+			Handler:           handler,
+			ReadHeaderTimeout: defaultReadHeaderTimeout,
+			IdleTimeout:       defaultIdleTimeout,
+			MaxHeaderBytes:    c.MaxHeaderBytes,
+		},
+	}
+	timeouts := []struct {
+		name  string
+		value string
+		dest  *time.Duration
+	}{
+		{"readTimeout", c.ReadTimeout, &s.server.ReadTimeout},
+		{"readHeaderTimeout", c.ReadHeaderTimeout, &s.server.ReadHeaderTimeout},
+		{"writeTimeout", c.WriteTimeout, &s.server.WriteTimeout},
+		{"idleTimeout", c.IdleTimeout, &s.server.IdleTimeout},
+	}
+	for _, t := range timeouts {
+		if t.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(t.value)
+		if err != nil {
+			return nil, fmt.Errorf("server.%s: %s", t.name, err.Error())
+		}
+		*t.dest = d
+	}
+	if c.TLS != nil {
+		tlsConfig, err := newTLSConfig(cfg.Logger, c.TLS)
+		if err != nil {
+			return nil, err
+		}
+		s.server.TLSConfig = tlsConfig
+		s.tls = true
+	} else if c.H2C {
+		s.server.Handler = h2c.NewHandler(s.server.Handler, &http2.Server{IdleTimeout: s.server.IdleTimeout})
+	}
+	return s, nil
+}
+
+// withMetrics serves the expvar variables on the given path, and the other requests
+// with handler.
+func withMetrics(path string, handler http.Handler) http.Handler {
+	metrics := expvar.Handler()
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == path {
+			metrics.ServeHTTP(w, req)
+			return
+		}
+		handler.ServeHTTP(w, req)
+	})
+}
+
+func (s *Server) ListenAndServe() error {
+	if s.tls {
+		s.logger.Info("server listening on %s (tls)", s.server.Addr)
+		// certificates are provided by TLSConfig.GetCertificate
+		return s.server.ListenAndServeTLS("", "")
+	}
+	s.logger.Info("server listening on %s", s.server.Addr)
+	return s.server.ListenAndServe()
+}
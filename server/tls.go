@@ -0,0 +1,136 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/skilld-labs/http-event-adapter/log"
+)
+
+type TLSConfiguration struct {
+	Cert     string `config:"cert"`
+	Key      string `config:"key"`
+	ClientCA string `config:"clientCA"`
+	// ClientAuth is one of request, require, verifyIfGiven (default when clientCA
+	// is set) and requireAndVerify.
+	ClientAuth string `config:"clientAuth"`
+	MinVersion string `config:"minVersion"`
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":             tls.NoClientCert,
+	"request":          tls.RequestClientCert,
+	"require":          tls.RequireAnyClientCert,
+	"verifyIfGiven":    tls.VerifyClientCertIfGiven,
+	"requireAndVerify": tls.RequireAndVerifyClientCert,
+}
+
+func newTLSConfig(logger log.Logger, cfg *TLSConfiguration) (*tls.Config, error) {
+	loader := &certificateLoader{logger: logger, cert: cfg.Cert, key: cfg.Key}
+	if err := loader.load(); err != nil {
+		return nil, err
+	}
+	c := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: loader.getCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+	if cfg.MinVersion != "" {
+		v, exists := tlsVersions[cfg.MinVersion]
+		if !exists {
+			return nil, fmt.Errorf("server.tls.minVersion: unknown version %s", cfg.MinVersion)
+		}
+		c.MinVersion = v
+	}
+	if cfg.ClientCA != "" {
+		pem, err := os.ReadFile(cfg.ClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("server.tls.clientCA: %s", err.Error())
+		}
+		c.ClientCAs = x509.NewCertPool()
+		if !c.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("server.tls.clientCA: no certificate found in %s", cfg.ClientCA)
+		}
+		c.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	if cfg.ClientAuth != "" {
+		a, exists := clientAuthTypes[cfg.ClientAuth]
+		if !exists {
+			return nil, fmt.Errorf("server.tls.clientAuth: unknown value %s", cfg.ClientAuth)
+		}
+		c.ClientAuth = a
+	}
+	return c, nil
+}
+
+// certificateLoader reloads the certificate when its files are modified.
+type certificateLoader struct {
+	logger log.Logger
+	cert   string
+	key    string
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	modTime     time.Time
+}
+
+func (l *certificateLoader) load() error {
+	modTime, err := l.lastModification()
+	if err != nil {
+		return err
+	}
+	certificate, err := tls.LoadX509KeyPair(l.cert, l.key)
+	if err != nil {
+		return fmt.Errorf("server.tls: %s", err.Error())
+	}
+	l.mu.Lock()
+	l.certificate, l.modTime = &certificate, modTime
+	l.mu.Unlock()
+	return nil
+}
+
+func (l *certificateLoader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	l.mu.RLock()
+	certificate, modTime := l.certificate, l.modTime
+	l.mu.RUnlock()
+	if m, err := l.lastModification(); err == nil && !m.Equal(modTime) {
+		if err := l.load(); err != nil {
+			l.logger.Err("error while reloading certificate, keeping the previous one (err : %s)", err.Error())
+			// do not retry until the files are modified again
+			l.mu.Lock()
+			l.modTime = m
+			l.mu.Unlock()
+		} else {
+			l.logger.Info("certificate %s has been reloaded", l.cert)
+			l.mu.RLock()
+			certificate = l.certificate
+			l.mu.RUnlock()
+		}
+	}
+	return certificate, nil
+}
+
+func (l *certificateLoader) lastModification() (time.Time, error) {
+	var last time.Time
+	for _, f := range []string{l.cert, l.key} {
+		info, err := os.Stat(f)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("server.tls: %s", err.Error())
+		}
+		if info.ModTime().After(last) {
+			last = info.ModTime()
+		}
+	}
+	return last, nil
+}
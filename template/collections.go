@@ -0,0 +1,309 @@
+package template
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+func list(vv ...interface{}) []interface{} {
+	return vv
+}
+
+// toList converts slices and arrays of any type to a list.
+func toList(v interface{}) ([]interface{}, error) {
+	if l, isList := v.([]interface{}); isList {
+		return l, nil
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return nil, nil
+	}
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("cannot use %T as a list", v)
+	}
+	l := make([]interface{}, rv.Len())
+	for i := range l {
+		l[i] = rv.Index(i).Interface()
+	}
+	return l, nil
+}
+
+func first(v interface{}) (interface{}, error) {
+	l, err := toList(v)
+	if err != nil || len(l) == 0 {
+		return nil, err
+	}
+	return l[0], nil
+}
+
+func last(v interface{}) (interface{}, error) {
+	l, err := toList(v)
+	if err != nil || len(l) == 0 {
+		return nil, err
+	}
+	return l[len(l)-1], nil
+}
+
+func rest(v interface{}) ([]interface{}, error) {
+	l, err := toList(v)
+	if err != nil || len(l) == 0 {
+		return nil, err
+	}
+	return append([]interface{}(nil), l[1:]...), nil
+}
+
+func initial(v interface{}) ([]interface{}, error) {
+	l, err := toList(v)
+	if err != nil || len(l) == 0 {
+		return nil, err
+	}
+	return append([]interface{}(nil), l[:len(l)-1]...), nil
+}
+
+func push(v interface{}, elem interface{}) ([]interface{}, error) {
+	l, err := toList(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]interface{}(nil), l...), elem), nil
+}
+
+func prepend(v interface{}, elem interface{}) ([]interface{}, error) {
+	l, err := toList(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]interface{}{elem}, l...), nil
+}
+
+func concat(vv ...interface{}) ([]interface{}, error) {
+	var c []interface{}
+	for _, v := range vv {
+		l, err := toList(v)
+		if err != nil {
+			return nil, err
+		}
+		c = append(c, l...)
+	}
+	return c, nil
+}
+
+func reverse(v interface{}) ([]interface{}, error) {
+	l, err := toList(v)
+	if err != nil {
+		return nil, err
+	}
+	r := make([]interface{}, len(l))
+	for i, elem := range l {
+		r[len(l)-1-i] = elem
+	}
+	return r, nil
+}
+
+func uniq(v interface{}) ([]interface{}, error) {
+	l, err := toList(v)
+	if err != nil {
+		return nil, err
+	}
+	var u []interface{}
+	for _, elem := range l {
+		if !contains(u, elem) {
+			u = append(u, elem)
+		}
+	}
+	return u, nil
+}
+
+func has(elem interface{}, v interface{}) (bool, error) {
+	l, err := toList(v)
+	if err != nil {
+		return false, err
+	}
+	return contains(l, elem), nil
+}
+
+func without(v interface{}, removed ...interface{}) ([]interface{}, error) {
+	l, err := toList(v)
+	if err != nil {
+		return nil, err
+	}
+	var w []interface{}
+	for _, elem := range l {
+		if !contains(removed, elem) {
+			w = append(w, elem)
+		}
+	}
+	return w, nil
+}
+
+func compact(v interface{}) ([]interface{}, error) {
+	l, err := toList(v)
+	if err != nil {
+		return nil, err
+	}
+	var c []interface{}
+	for _, elem := range l {
+		if !empty(elem) {
+			c = append(c, elem)
+		}
+	}
+	return c, nil
+}
+
+func sortAlpha(v interface{}) []string {
+	s := append([]string(nil), toStrings(v)...)
+	sort.Strings(s)
+	return s
+}
+
+// slice returns the elements from start to end, end being optional.
+func slice(v interface{}, indexes ...int) ([]interface{}, error) {
+	l, err := toList(v)
+	if err != nil {
+		return nil, err
+	}
+	start, end := 0, len(l)
+	if len(indexes) > 0 {
+		start = indexes[0]
+	}
+	if len(indexes) > 1 {
+		end = indexes[1]
+	}
+	if start < 0 || end > len(l) || start > end {
+		return nil, fmt.Errorf("slice bounds [%d:%d] out of range for %d elements", start, end, len(l))
+	}
+	return l[start:end], nil
+}
+
+func until(n int) []int {
+	l := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		l = append(l, i)
+	}
+	return l
+}
+
+func contains(l []interface{}, elem interface{}) bool {
+	for _, e := range l {
+		if reflect.DeepEqual(e, elem) {
+			return true
+		}
+	}
+	return false
+}
+
+func dict(kv ...interface{}) map[string]interface{} {
+	d := make(map[string]interface{}, len(kv)/2)
+	for i := 0; i < len(kv); i += 2 {
+		var v interface{}
+		if i+1 < len(kv) {
+			v = kv[i+1]
+		}
+		d[toString(kv[i])] = v
+	}
+	return d
+}
+
+func get(d map[string]interface{}, key string) interface{} {
+	return d[key]
+}
+
+// set returns a copy of the map with the key set, templates data being shared by
+// the templates of an event.
+func set(d map[string]interface{}, key string, v interface{}) map[string]interface{} {
+	c := copyDict(d)
+	c[key] = v
+	return c
+}
+
+// unset returns a copy of the map without the key.
+func unset(d map[string]interface{}, key string) map[string]interface{} {
+	c := copyDict(d)
+	delete(c, key)
+	return c
+}
+
+func copyDict(d map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(d))
+	for key, v := range d {
+		c[key] = v
+	}
+	return c
+}
+
+func hasKey(d map[string]interface{}, key string) bool {
+	_, exists := d[key]
+	return exists
+}
+
+// keys returns the sorted keys of the maps.
+func keys(dd ...map[string]interface{}) []string {
+	var k []string
+	for _, d := range dd {
+		for key := range d {
+			k = append(k, key)
+		}
+	}
+	sort.Strings(k)
+	return k
+}
+
+// values returns the values of the map, sorted by key.
+func values(d map[string]interface{}) []interface{} {
+	v := make([]interface{}, 0, len(d))
+	for _, key := range keys(d) {
+		v = append(v, d[key])
+	}
+	return v
+}
+
+func pick(d map[string]interface{}, picked ...string) map[string]interface{} {
+	p := make(map[string]interface{}, len(picked))
+	for _, key := range picked {
+		if v, exists := d[key]; exists {
+			p[key] = v
+		}
+	}
+	return p
+}
+
+func omit(d map[string]interface{}, omitted ...string) map[string]interface{} {
+	o := copyDict(d)
+	for _, key := range omitted {
+		delete(o, key)
+	}
+	return o
+}
+
+// merge returns a copy of the first map completed with the keys of the next ones it
+// does not have, maps found under the same key in both being merged the same way.
+func merge(dst map[string]interface{}, srcs ...map[string]interface{}) map[string]interface{} {
+	merged := copyDict(dst)
+	for _, src := range srcs {
+		for key, v := range src {
+			existing, exists := merged[key]
+			if !exists {
+				merged[key] = v
+				continue
+			}
+			dstMap, isDstMap := existing.(map[string]interface{})
+			srcMap, isSrcMap := v.(map[string]interface{})
+			if isDstMap && isSrcMap {
+				merged[key] = merge(dstMap, srcMap)
+			}
+		}
+	}
+	return merged
+}
+
+// pluck returns the values of the key in the maps having it.
+func pluck(key string, dd ...map[string]interface{}) []interface{} {
+	var p []interface{}
+	for _, d := range dd {
+		if v, exists := d[key]; exists {
+			p = append(p, v)
+		}
+	}
+	return p
+}
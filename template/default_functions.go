@@ -7,8 +7,11 @@ import (
 	"time"
 )
 
+// GetDefaultFuncs returns the functions available in all templates, the library
+// functions and the historical ones.
 func GetDefaultFuncs() gotemplate.FuncMap {
-	return map[string]interface{}{
+	funcs := GetLibraryFuncs()
+	for name, f := range map[string]interface{}{
 		"Now":                Now,
 		"NowUnix":            NowUnix,
 		"NowUnixNano":        NowUnixNano,
@@ -17,11 +20,18 @@ func GetDefaultFuncs() gotemplate.FuncMap {
 		"TimeParse":          TimeParse,
 		"MustTimeParse":      MustTimeParse,
 		"ToLower":            ToLower,
+		"ToUpper":            ToUpper,
 		"Replace":            Replace,
 		"Split":              Split,
 		"ParseFloat":         ParseFloat,
 		"MustParseFloat":     MustParseFloat,
+		"ToJSON":             ToJSON,
+		"ToPrettyJSON":       ToPrettyJSON,
+		"JSONEscape":         JSONEscape,
+	} {
+		funcs[name] = f
 	}
+	return funcs
 }
 
 func Now(vv ...interface{}) string {
@@ -75,6 +85,10 @@ func ToLower(str string) string {
 	return strings.ToLower(str)
 }
 
+func ToUpper(str string) string {
+	return strings.ToUpper(str)
+}
+
 func Replace(s, old, new string, n int) string {
 	return strings.Replace(s, old, new, n)
 }
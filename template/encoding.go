@@ -0,0 +1,136 @@
+package template
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/adler32"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func b64enc(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func b64dec(s string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	return string(b), err
+}
+
+func fromJson(s string) (interface{}, error) {
+	var v interface{}
+	return v, json.Unmarshal([]byte(s), &v)
+}
+
+func sha1sum(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func sha256sum(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func sha512sum(s string) string {
+	sum := sha512.Sum512([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func md5sum(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func adler32sum(s string) string {
+	return strconv.FormatUint(uint64(adler32.Checksum([]byte(s))), 10)
+}
+
+// uuidv4 returns a random (version 4) UUID.
+func uuidv4() (string, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return "", err
+	}
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:]), nil
+}
+
+func regexMatch(regex, s string) (bool, error) {
+	return regexp.MatchString(regex, s)
+}
+
+func regexFind(regex, s string) (string, error) {
+	r, err := regexp.Compile(regex)
+	if err != nil {
+		return "", err
+	}
+	return r.FindString(s), nil
+}
+
+// regexFindAll returns at most n matches, all of them if n is negative.
+func regexFindAll(regex, s string, n int) ([]string, error) {
+	r, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, err
+	}
+	return r.FindAllString(s, n), nil
+}
+
+// regexReplaceAll replaces the matches, expanding $1 like references to groups.
+func regexReplaceAll(regex, s, repl string) (string, error) {
+	r, err := regexp.Compile(regex)
+	if err != nil {
+		return "", err
+	}
+	return r.ReplaceAllString(s, repl), nil
+}
+
+func regexReplaceAllLiteral(regex, s, repl string) (string, error) {
+	r, err := regexp.Compile(regex)
+	if err != nil {
+		return "", err
+	}
+	return r.ReplaceAllLiteralString(s, repl), nil
+}
+
+func regexSplit(regex, s string, n int) ([]string, error) {
+	r, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, err
+	}
+	return r.Split(s, n), nil
+}
+
+func regexQuoteMeta(s string) string {
+	return regexp.QuoteMeta(s)
+}
+
+// envPrefix restricts the environment variables templates can read, so that they
+// cannot read the secrets of the adapter.
+const envPrefix = "TEMPLATE_"
+
+// env returns the value of an environment variable whose name starts with envPrefix,
+// other variables being empty.
+func env(name string) string {
+	if !strings.HasPrefix(name, envPrefix) {
+		return ""
+	}
+	return os.Getenv(name)
+}
+
+// expandenv replaces the $VAR and ${VAR} references of the string by the value of
+// env.
+func expandenv(s string) string {
+	return os.Expand(s, env)
+}
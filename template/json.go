@@ -0,0 +1,220 @@
+package template
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	gotemplate "text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// jsonStringFunc renders a string of a JSON skeleton, with the template whose name
+	// is given, as a JSON string.
+	jsonStringFunc = "jsonString"
+	// stringTemplateSeparator separates the name of a JSON skeleton template from the
+	// JSON pointer of its strings, which are parsed as templates of their own.
+	stringTemplateSeparator = "#"
+)
+
+var (
+	singleAction    = regexp.MustCompile(`^\{\{-?\s*(.*?)\s*-?\}\}$`)
+	controlKeywords = regexp.MustCompile(`^(if|else|end|range|with|define|template|block|break|continue)\b|^/\*|:?=`)
+	pointerEscaper  = strings.NewReplacer("~", "~0", "/", "~1")
+)
+
+// ToJSON returns the JSON encoding of the value.
+func ToJSON(v interface{}) (string, error) {
+	b, err := marshal(v)
+	return string(b), err
+}
+
+// ToPrettyJSON returns the indented JSON encoding of the value.
+func ToPrettyJSON(v interface{}) (string, error) {
+	b, err := marshal(v)
+	if err != nil {
+		return "", err
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, b, "", "  "); err != nil {
+		return "", err
+	}
+	return indented.String(), nil
+}
+
+// JSONEscape escapes the string so that it can be written between quotes in a JSON
+// document.
+func JSONEscape(s string) string {
+	b, _ := marshal(s)
+	return string(b[1 : len(b)-1])
+}
+
+// ParseJSONSkeleton parses a YAML or JSON document, whose string values may hold
+// template actions, into a template rendering a JSON document. Values made of a
+// single action keep the type of the action result. Other strings holding actions
+// are parsed as templates of their own, named after the template and the JSON
+// pointer of the value (eg: "out.yaml#/greeting"), whose output is encoded as a JSON
+// string so that rendered data cannot change the structure of the document. Copies
+// of the template must be bound again by BindJSONSkeleton.
+func ParseJSONSkeleton(name string, skeleton []byte, funcs gotemplate.FuncMap) (*gotemplate.Template, error) {
+	// decoding in a MapSlice keeps the order of the keys of the skeleton
+	var doc interface{}
+	var ms yaml.MapSlice
+	if err := yaml.Unmarshal(skeleton, &ms); err == nil {
+		doc = ms
+	} else if err := yaml.Unmarshal(skeleton, &doc); err != nil {
+		return nil, err
+	}
+	tmpl := gotemplate.New(name).Funcs(funcs).Funcs(gotemplate.FuncMap{
+		"ToJSON":       ToJSON,
+		jsonStringFunc: func(string, interface{}) (string, error) { return "", nil },
+	})
+	var b strings.Builder
+	if err := writeSkeleton(&b, tmpl, "", doc); err != nil {
+		return nil, err
+	}
+	if _, err := tmpl.Parse(b.String()); err != nil {
+		return nil, err
+	}
+	return BindJSONSkeleton(tmpl), nil
+}
+
+// BindJSONSkeleton makes a template parsed by ParseJSONSkeleton render its strings
+// with its own functions, it returns other templates unchanged.
+func BindJSONSkeleton(tmpl *gotemplate.Template) *gotemplate.Template {
+	for _, t := range tmpl.Templates() {
+		if strings.HasPrefix(t.Name(), tmpl.Name()+stringTemplateSeparator) {
+			return tmpl.Funcs(gotemplate.FuncMap{jsonStringFunc: func(name string, data interface{}) (string, error) {
+				var b strings.Builder
+				if err := tmpl.ExecuteTemplate(&b, name, data); err != nil {
+					return "", err
+				}
+				return ToJSON(b.String())
+			}})
+		}
+	}
+	return tmpl
+}
+
+// FinishJSON checks that the output of a JSON skeleton template is a valid JSON
+// document and compacts it.
+func FinishJSON(out []byte) ([]byte, error) {
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, out); err != nil {
+		return nil, fmt.Errorf("rendered output is not valid JSON: %s", err.Error())
+	}
+	return compacted.Bytes(), nil
+}
+
+func writeSkeleton(b *strings.Builder, tmpl *gotemplate.Template, pointer string, v interface{}) error {
+	switch value := v.(type) {
+	case yaml.MapSlice:
+		b.WriteString("{")
+		for i, item := range value {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			key := fmt.Sprint(item.Key)
+			writeJSON(b, key)
+			b.WriteString(":")
+			if err := writeSkeleton(b, tmpl, pointer+"/"+pointerEscaper.Replace(key), item.Value); err != nil {
+				return err
+			}
+		}
+		b.WriteString("}")
+	case map[interface{}]interface{}:
+		keys := make([]string, 0, len(value))
+		values := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			keys = append(keys, fmt.Sprint(k))
+			values[fmt.Sprint(k)] = item
+		}
+		sort.Strings(keys)
+		ms := make(yaml.MapSlice, len(keys))
+		for i, k := range keys {
+			ms[i] = yaml.MapItem{Key: k, Value: values[k]}
+		}
+		return writeSkeleton(b, tmpl, pointer, ms)
+	case []interface{}:
+		b.WriteString("[")
+		for i, item := range value {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			if err := writeSkeleton(b, tmpl, fmt.Sprintf("%s/%d", pointer, i), item); err != nil {
+				return err
+			}
+		}
+		b.WriteString("]")
+	case string:
+		if !strings.Contains(value, "{{") {
+			writeJSON(b, value)
+			return nil
+		}
+		if m := singleAction.FindStringSubmatch(strings.TrimSpace(value)); m != nil && !strings.Contains(m[1], "}}") && !controlKeywords.MatchString(m[1]) {
+			b.WriteString("{{ ToJSON (" + m[1] + ") }}")
+			return nil
+		}
+		name := tmpl.Name() + stringTemplateSeparator + pointer
+		if _, err := tmpl.New(name).Parse(value); err != nil {
+			return err
+		}
+		b.WriteString("{{ " + jsonStringFunc + " " + strconv.Quote(name) + " . }}")
+	default:
+		writeJSON(b, value)
+	}
+	return nil
+}
+
+func writeJSON(b *strings.Builder, v interface{}) {
+	j, _ := marshal(v)
+	b.Write(j)
+}
+
+// marshal encodes the value in JSON without escaping HTML characters.
+func marshal(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(normalize(v)); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
+
+// normalize converts the maps decoded from YAML, whose keys are not strings, so that
+// they can be encoded in JSON.
+func normalize(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			m[fmt.Sprint(k)] = normalize(item)
+		}
+		return m
+	case yaml.MapSlice:
+		m := make(map[string]interface{}, len(value))
+		for _, item := range value {
+			m[fmt.Sprint(item.Key)] = normalize(item.Value)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			m[k] = normalize(item)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(value))
+		for i, item := range value {
+			l[i] = normalize(item)
+		}
+		return l
+	}
+	return v
+}
@@ -0,0 +1,509 @@
+package template
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	gotemplate "text/template"
+	"time"
+	"unicode"
+)
+
+// GetLibraryFuncs returns general purpose functions, named after their Sprig
+// (https://masterminds.github.io/sprig/) equivalents. Like in Sprig, the value a
+// function works on is its last argument so that it can be piped.
+func GetLibraryFuncs() gotemplate.FuncMap {
+	return map[string]interface{}{
+		// strings
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+		"title":      title,
+		"trim":       strings.TrimSpace,
+		"trimAll":    func(cutset, s string) string { return strings.Trim(s, cutset) },
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"repeat":     func(count int, s string) string { return strings.Repeat(s, count) },
+		"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"splitList":  func(sep, s string) []string { return strings.Split(s, sep) },
+		"join":       join,
+		"quote":      quote,
+		"squote":     squote,
+		"cat":        cat,
+		"substr":     substr,
+		"trunc":      trunc,
+		"nospace":    nospace,
+		"indent":     indent,
+		"nindent":    func(spaces int, s string) string { return "\n" + indent(spaces, s) },
+		// defaults and flow
+		"default":  dfault,
+		"empty":    empty,
+		"coalesce": coalesce,
+		"ternary":  ternary,
+		"fail":     func(msg string) (string, error) { return "", errors.New(msg) },
+		"required": required,
+		// conversions
+		"toString":  toString,
+		"toStrings": toStrings,
+		"atoi":      func(s string) int { i, _ := strconv.Atoi(strings.TrimSpace(s)); return i },
+		"int":       func(v interface{}) int { return int(toInt64(v)) },
+		"int64":     toInt64,
+		"float64":   toFloat64,
+		"toBool":    toBool,
+		"typeOf":    func(v interface{}) string { return fmt.Sprintf("%T", v) },
+		"kindOf":    kindOf,
+		// math
+		"add":   add,
+		"add1":  func(v interface{}) int64 { return toInt64(v) + 1 },
+		"sub":   func(a, b interface{}) int64 { return toInt64(a) - toInt64(b) },
+		"mul":   mul,
+		"div":   div,
+		"mod":   mod,
+		"max":   maxInt,
+		"min":   minInt,
+		"addf":  addf,
+		"subf":  func(a, b interface{}) float64 { return toFloat64(a) - toFloat64(b) },
+		"mulf":  mulf,
+		"divf":  divf,
+		"maxf":  maxf,
+		"minf":  minf,
+		"floor": func(v interface{}) float64 { return math.Floor(toFloat64(v)) },
+		"ceil":  func(v interface{}) float64 { return math.Ceil(toFloat64(v)) },
+		"round": round,
+		// dates
+		"now":        time.Now,
+		"date":       date,
+		"dateInZone": dateInZone,
+		"unixEpoch":  func(t time.Time) string { return strconv.FormatInt(t.Unix(), 10) },
+		"toDate":     func(layout, s string) (time.Time, error) { return time.Parse(layout, s) },
+		// lists
+		"list":      list,
+		"first":     first,
+		"last":      last,
+		"rest":      rest,
+		"initial":   initial,
+		"append":    push,
+		"prepend":   prepend,
+		"concat":    concat,
+		"reverse":   reverse,
+		"uniq":      uniq,
+		"has":       has,
+		"without":   without,
+		"compact":   compact,
+		"sortAlpha": sortAlpha,
+		"slice":     slice,
+		"until":     until,
+		// dicts
+		"dict":   dict,
+		"get":    get,
+		"set":    set,
+		"unset":  unset,
+		"hasKey": hasKey,
+		"keys":   keys,
+		"values": values,
+		"pick":   pick,
+		"omit":   omit,
+		"merge":  merge,
+		"pluck":  pluck,
+		// encodings, hashes and identifiers
+		"b64enc":       b64enc,
+		"b64dec":       b64dec,
+		"toJson":       ToJSON,
+		"toPrettyJson": ToPrettyJSON,
+		"fromJson":     fromJson,
+		"sha1sum":      sha1sum,
+		"sha256sum":    sha256sum,
+		"sha512sum":    sha512sum,
+		"md5sum":       md5sum,
+		"adler32sum":   adler32sum,
+		"uuidv4":       uuidv4,
+		// regular expressions
+		"regexMatch":             regexMatch,
+		"regexFind":              regexFind,
+		"regexFindAll":           regexFindAll,
+		"regexReplaceAll":        regexReplaceAll,
+		"regexReplaceAllLiteral": regexReplaceAllLiteral,
+		"regexSplit":             regexSplit,
+		"regexQuoteMeta":         regexQuoteMeta,
+		// environment
+		"env":       env,
+		"expandenv": expandenv,
+	}
+}
+
+// title upper cases the first letter of each word.
+func title(s string) string {
+	r := []rune(s)
+	for i := range r {
+		if i == 0 || unicode.IsSpace(r[i-1]) || unicode.IsPunct(r[i-1]) && r[i-1] != '\'' {
+			r[i] = unicode.ToTitle(r[i])
+		}
+	}
+	return string(r)
+}
+
+func join(sep string, v interface{}) string {
+	return strings.Join(toStrings(v), sep)
+}
+
+func quote(vv ...interface{}) string {
+	quoted := make([]string, 0, len(vv))
+	for _, v := range vv {
+		if v != nil {
+			quoted = append(quoted, strconv.Quote(toString(v)))
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+func squote(vv ...interface{}) string {
+	quoted := make([]string, 0, len(vv))
+	for _, v := range vv {
+		if v != nil {
+			quoted = append(quoted, "'"+toString(v)+"'")
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+func cat(vv ...interface{}) string {
+	s := make([]string, 0, len(vv))
+	for _, v := range vv {
+		if v != nil {
+			s = append(s, toString(v))
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+// substr returns the runes from start to end, a negative end meaning the end of
+// the string.
+func substr(start, end int, s string) string {
+	r := []rune(s)
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 || end > len(r) {
+		end = len(r)
+	}
+	if start > end {
+		return ""
+	}
+	return string(r[start:end])
+}
+
+// trunc keeps the first n runes, or the last -n runes if n is negative.
+func trunc(n int, s string) string {
+	r := []rune(s)
+	if n >= 0 && len(r) > n {
+		return string(r[:n])
+	}
+	if n < 0 && len(r) > -n {
+		return string(r[len(r)+n:])
+	}
+	return s
+}
+
+func nospace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func indent(spaces int, s string) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+// dfault returns the value, or the default if the value is empty.
+func dfault(d interface{}, v ...interface{}) interface{} {
+	if len(v) == 0 || empty(v[0]) {
+		return d
+	}
+	return v[0]
+}
+
+// empty tells whether the value is nil or the zero value of its type, empty
+// strings, lists and maps being empty.
+func empty(v interface{}) bool {
+	if n, isNumber := v.(json.Number); isNumber {
+		// numbers of decoded inputs are empty when they are zero
+		f, err := n.Float64()
+		return err != nil || f == 0
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return true
+	}
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	}
+	return rv.IsZero()
+}
+
+func coalesce(vv ...interface{}) interface{} {
+	for _, v := range vv {
+		if !empty(v) {
+			return v
+		}
+	}
+	return nil
+}
+
+func ternary(whenTrue, whenFalse interface{}, condition bool) interface{} {
+	if condition {
+		return whenTrue
+	}
+	return whenFalse
+}
+
+func required(msg string, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, errors.New(msg)
+	}
+	if s, isString := v.(string); isString && s == "" {
+		return nil, errors.New(msg)
+	}
+	return v, nil
+}
+
+func kindOf(v interface{}) string {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return "invalid"
+	}
+	return rv.Kind().String()
+}
+
+func toString(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case []byte:
+		return string(value)
+	case error:
+		return value.Error()
+	case fmt.Stringer:
+		return value.String()
+	}
+	return fmt.Sprint(v)
+}
+
+func toStrings(v interface{}) []string {
+	switch value := v.(type) {
+	case nil:
+		return nil
+	case []string:
+		return value
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return []string{toString(v)}
+	}
+	s := make([]string, rv.Len())
+	for i := range s {
+		s[i] = toString(rv.Index(i).Interface())
+	}
+	return s
+}
+
+func toInt64(v interface{}) int64 {
+	switch value := v.(type) {
+	case string:
+		if i, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil {
+			return i
+		}
+		return int64(toFloat64(value))
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		return int64(toFloat64(value))
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return int64(rv.Float())
+	case reflect.Bool:
+		if rv.Bool() {
+			return 1
+		}
+	}
+	return 0
+}
+
+func toFloat64(v interface{}) float64 {
+	switch value := v.(type) {
+	case string:
+		f, _ := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return f
+	case json.Number:
+		f, _ := value.Float64()
+		return f
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.Bool:
+		if rv.Bool() {
+			return 1
+		}
+	}
+	return 0
+}
+
+func toBool(v interface{}) bool {
+	switch value := v.(type) {
+	case bool:
+		return value
+	case string:
+		b, _ := strconv.ParseBool(strings.TrimSpace(value))
+		return b
+	case nil:
+		return false
+	}
+	return toFloat64(v) != 0
+}
+
+func add(vv ...interface{}) int64 {
+	var sum int64
+	for _, v := range vv {
+		sum += toInt64(v)
+	}
+	return sum
+}
+
+func mul(a interface{}, vv ...interface{}) int64 {
+	product := toInt64(a)
+	for _, v := range vv {
+		product *= toInt64(v)
+	}
+	return product
+}
+
+func div(a, b interface{}) (int64, error) {
+	if toInt64(b) == 0 {
+		return 0, errors.New("division by zero")
+	}
+	return toInt64(a) / toInt64(b), nil
+}
+
+func mod(a, b interface{}) (int64, error) {
+	if toInt64(b) == 0 {
+		return 0, errors.New("division by zero")
+	}
+	return toInt64(a) % toInt64(b), nil
+}
+
+func maxInt(a interface{}, vv ...interface{}) int64 {
+	m := toInt64(a)
+	for _, v := range vv {
+		if i := toInt64(v); i > m {
+			m = i
+		}
+	}
+	return m
+}
+
+func minInt(a interface{}, vv ...interface{}) int64 {
+	m := toInt64(a)
+	for _, v := range vv {
+		if i := toInt64(v); i < m {
+			m = i
+		}
+	}
+	return m
+}
+
+func addf(vv ...interface{}) float64 {
+	var sum float64
+	for _, v := range vv {
+		sum += toFloat64(v)
+	}
+	return sum
+}
+
+func mulf(a interface{}, vv ...interface{}) float64 {
+	product := toFloat64(a)
+	for _, v := range vv {
+		product *= toFloat64(v)
+	}
+	return product
+}
+
+func divf(a, b interface{}) (float64, error) {
+	if toFloat64(b) == 0 {
+		return 0, errors.New("division by zero")
+	}
+	return toFloat64(a) / toFloat64(b), nil
+}
+
+func maxf(a interface{}, vv ...interface{}) float64 {
+	m := toFloat64(a)
+	for _, v := range vv {
+		m = math.Max(m, toFloat64(v))
+	}
+	return m
+}
+
+func minf(a interface{}, vv ...interface{}) float64 {
+	m := toFloat64(a)
+	for _, v := range vv {
+		m = math.Min(m, toFloat64(v))
+	}
+	return m
+}
+
+// round rounds the value to the given number of decimals.
+func round(v interface{}, decimals int) float64 {
+	pow := math.Pow(10, float64(decimals))
+	return math.Round(toFloat64(v)*pow) / pow
+}
+
+// date formats a time, or a unix timestamp, in the local time zone.
+func date(layout string, t interface{}) string {
+	return dateInZone(layout, t, "Local")
+}
+
+func dateInZone(layout string, t interface{}, zone string) string {
+	var tm time.Time
+	switch value := t.(type) {
+	case time.Time:
+		tm = value
+	case *time.Time:
+		if value == nil {
+			return ""
+		}
+		tm = *value
+	default:
+		tm = time.Unix(toInt64(t), 0)
+	}
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		location = time.UTC
+	}
+	return tm.In(location).Format(layout)
+}
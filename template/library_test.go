@@ -0,0 +1,293 @@
+package template
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	gotemplate "text/template"
+	"time"
+)
+
+type libraryTest struct {
+	name string
+	tmpl string
+	want string
+	// err, if set, is a part of the expected error
+	err string
+}
+
+func runLibraryTests(t *testing.T, tests []libraryTest, data interface{}) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := execute(tt.tmpl, data)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("%s: expected error containing %q, got %q (err: %v)", tt.tmpl, tt.err, got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("%s: %s", tt.tmpl, err.Error())
+			}
+			if got != tt.want {
+				t.Errorf("%s: got %q, want %q", tt.tmpl, got, tt.want)
+			}
+		})
+	}
+}
+
+func execute(text string, data interface{}) (string, error) {
+	tmpl, err := gotemplate.New("test").Funcs(GetDefaultFuncs()).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func TestStringFunctions(t *testing.T) {
+	runLibraryTests(t, []libraryTest{
+		{"upper", `{{ "abc" | upper }}`, "ABC", ""},
+		{"lower", `{{ "ABC" | lower }}`, "abc", ""},
+		{"title", `{{ "hello it's world-wide" | title }}`, "Hello It's World-Wide", ""},
+		{"trim", `{{ "  a b  " | trim }}`, "a b", ""},
+		{"trimAll", `{{ "$5.00$" | trimAll "$" }}`, "5.00", ""},
+		{"trimPrefix", `{{ "prefix-x" | trimPrefix "prefix-" }}`, "x", ""},
+		{"trimSuffix", `{{ "x.json" | trimSuffix ".json" }}`, "x", ""},
+		{"contains", `{{ "haystack" | contains "st" }}`, "true", ""},
+		{"hasPrefix", `{{ "haystack" | hasPrefix "hay" }}`, "true", ""},
+		{"hasSuffix", `{{ "haystack" | hasSuffix "hay" }}`, "false", ""},
+		{"repeat", `{{ "ab" | repeat 3 }}`, "ababab", ""},
+		{"replace", `{{ "a-b-c" | replace "-" "_" }}`, "a_b_c", ""},
+		{"splitList", `{{ "a,b,c" | splitList "," }}`, "[a b c]", ""},
+		{"join", `{{ list 1 "b" 3 | join "-" }}`, "1-b-3", ""},
+		{"quote", `{{ quote "a" nil 1 }}`, `"a" "1"`, ""},
+		{"squote", `{{ "a" | squote }}`, "'a'", ""},
+		{"cat", `{{ cat "a" nil 2 }}`, "a 2", ""},
+		{"substr", `{{ "hello" | substr 1 3 }}`, "el", ""},
+		{"substr to the end", `{{ "héllo" | substr 1 -1 }}`, "éllo", ""},
+		{"trunc", `{{ "hello" | trunc 3 }}`, "hel", ""},
+		{"trunc from the end", `{{ "hello" | trunc -2 }}`, "lo", ""},
+		{"nospace", "{{ \" a b\\tc \" | nospace }}", "abc", ""},
+		{"indent", `{{ "a\nb" | indent 2 }}`, "  a\n  b", ""},
+		{"nindent", `{{ "a\nb" | nindent 2 }}`, "\n  a\n  b", ""},
+	}, nil)
+}
+
+func TestFlowFunctions(t *testing.T) {
+	data := map[string]interface{}{"name": "alice"}
+	runLibraryTests(t, []libraryTest{
+		{"default of empty string", `{{ "" | default "x" }}`, "x", ""},
+		{"default of zero", `{{ 0 | default 5 }}`, "5", ""},
+		{"default of value", `{{ .name | default "x" }}`, "alice", ""},
+		{"default of missing key", `{{ .missing | default "x" }}`, "x", ""},
+		{"empty", `{{ empty "" }} {{ empty list }} {{ empty 1 }} {{ empty .missing }}`, "true true false true", ""},
+		{"coalesce", `{{ coalesce "" 0 .missing "x" }}`, "x", ""},
+		{"ternary", `{{ true | ternary "y" "n" }} {{ false | ternary "y" "n" }}`, "y n", ""},
+		{"fail", `{{ fail "boom" }}`, "", "boom"},
+		{"required", `{{ .name | required "name is required" }}`, "alice", ""},
+		{"required of missing key", `{{ .missing | required "missing is required" }}`, "", "missing is required"},
+		{"required of empty string", `{{ "" | required "value is required" }}`, "", "value is required"},
+	}, data)
+}
+
+func TestConversionFunctions(t *testing.T) {
+	runLibraryTests(t, []libraryTest{
+		{"toString", `{{ 12 | toString | printf "%q" }}`, `"12"`, ""},
+		{"toStrings", `{{ list 1 "a" | toStrings | printf "%q" }}`, `["1" "a"]`, ""},
+		{"atoi", `{{ " 42 " | atoi }}`, "42", ""},
+		{"atoi of invalid", `{{ "x" | atoi }}`, "0", ""},
+		{"int", `{{ "3.7" | int }}`, "3", ""},
+		{"int64", `{{ 2.9 | int64 }}`, "2", ""},
+		{"float64", `{{ "1.5" | float64 }}`, "1.5", ""},
+		{"toBool", `{{ "true" | toBool }} {{ 0 | toBool }} {{ 2 | toBool }}`, "true false true", ""},
+		{"typeOf", `{{ 1 | typeOf }}`, "int", ""},
+		{"kindOf", `{{ list | kindOf }}`, "slice", ""},
+	}, nil)
+}
+
+func TestMathFunctions(t *testing.T) {
+	runLibraryTests(t, []libraryTest{
+		{"add", `{{ add 1 "2" 3.9 }}`, "6", ""},
+		{"add1", `{{ 1 | add1 }}`, "2", ""},
+		{"sub", `{{ sub 5 2 }}`, "3", ""},
+		{"mul", `{{ mul 2 3 4 }}`, "24", ""},
+		{"div", `{{ div 7 2 }}`, "3", ""},
+		{"div by zero", `{{ div 1 0 }}`, "", "division by zero"},
+		{"mod", `{{ mod 7 3 }}`, "1", ""},
+		{"mod by zero", `{{ mod 7 0 }}`, "", "division by zero"},
+		{"max", `{{ max 1 5 3 }}`, "5", ""},
+		{"min", `{{ min 4 1 3 }}`, "1", ""},
+		{"addf", `{{ addf 1.5 2 }}`, "3.5", ""},
+		{"subf", `{{ subf 1.5 0.5 }}`, "1", ""},
+		{"mulf", `{{ mulf 1.5 2 }}`, "3", ""},
+		{"divf", `{{ divf 1 4 }}`, "0.25", ""},
+		{"divf by zero", `{{ divf 1 0 }}`, "", "division by zero"},
+		{"maxf", `{{ maxf 1.5 2.5 0 }}`, "2.5", ""},
+		{"minf", `{{ minf 1.5 2.5 }}`, "1.5", ""},
+		{"floor", `{{ floor 1.7 }}`, "1", ""},
+		{"ceil", `{{ ceil 1.2 }}`, "2", ""},
+		{"round", `{{ round 3.14159 2 }}`, "3.14", ""},
+		{"round of a pipeline", `{{ round (mulf 0.25 0.5) 1 }}`, "0.1", ""},
+	}, nil)
+}
+
+func TestJSONNumberConversions(t *testing.T) {
+	dec := json.NewDecoder(strings.NewReader(`{"n": 3, "f": 1.5, "big": 9007199254740993, "zero": 0}`))
+	dec.UseNumber()
+	var data map[string]interface{}
+	if err := dec.Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	runLibraryTests(t, []libraryTest{
+		{"add", `{{ add .n 1 }}`, "4", ""},
+		{"addf", `{{ addf .f 1 }}`, "2.5", ""},
+		{"int of a decimal", `{{ .f | int }}`, "1", ""},
+		{"int64 keeps precision", `{{ .big | int64 }}`, "9007199254740993", ""},
+		{"float64", `{{ .n | float64 }}`, "3", ""},
+		{"max", `{{ max .n 10 }}`, "10", ""},
+		{"round", `{{ round .f 0 }}`, "2", ""},
+		{"toBool", `{{ .n | toBool }} {{ .zero | toBool }}`, "true false", ""},
+		{"div by zero", `{{ div .n .zero }}`, "", "division by zero"},
+		{"default of zero", `{{ .zero | default 5 }}`, "5", ""},
+		{"default of non zero", `{{ .n | default 5 }}`, "3", ""},
+		{"empty", `{{ empty .zero }} {{ empty .f }}`, "true false", ""},
+	}, data)
+}
+
+func TestDateFunctions(t *testing.T) {
+	data := map[string]interface{}{"t": time.Date(2020, 6, 15, 12, 30, 0, 0, time.UTC), "nilTime": (*time.Time)(nil)}
+	runLibraryTests(t, []libraryTest{
+		{"now", `{{ now | typeOf }}`, "time.Time", ""},
+		{"date", `{{ .t | date "2006" }}`, "2020", ""},
+		{"dateInZone", `{{ dateInZone "2006-01-02 15:04" .t "Europe/Paris" }}`, "2020-06-15 14:30", ""},
+		{"dateInZone of a timestamp", `{{ dateInZone "2006-01-02 15:04" 86400 "UTC" }}`, "1970-01-02 00:00", ""},
+		{"dateInZone of a nil time", `{{ dateInZone "2006-01-02 15:04" .nilTime "UTC" }}`, "", ""},
+		{"unixEpoch", `{{ .t | unixEpoch }}`, "1592224200", ""},
+		{"toDate", `{{ toDate "2006-01-02" "2020-06-15" | unixEpoch }}`, "1592179200", ""},
+		{"toDate of invalid", `{{ toDate "2006-01-02" "x" }}`, "", "cannot parse"},
+	}, data)
+}
+
+func TestListFunctions(t *testing.T) {
+	data := map[string]interface{}{"strings": []string{"b", "a"}}
+	runLibraryTests(t, []libraryTest{
+		{"list", `{{ list 1 "a" }}`, "[1 a]", ""},
+		{"first", `{{ list 1 2 3 | first }}`, "1", ""},
+		{"first of empty", `{{ list | first }}`, "<no value>", ""},
+		{"first of typed slice", `{{ .strings | first }}`, "b", ""},
+		{"first of non list", `{{ first 1 }}`, "", "cannot use int as a list"},
+		{"last", `{{ list 1 2 3 | last }}`, "3", ""},
+		{"rest", `{{ list 1 2 3 | rest }}`, "[2 3]", ""},
+		{"initial", `{{ list 1 2 3 | initial }}`, "[1 2]", ""},
+		{"append", `{{ append (list 1) 2 }}`, "[1 2]", ""},
+		{"prepend", `{{ prepend (list 2) 1 }}`, "[1 2]", ""},
+		{"concat", `{{ concat (list 1) .strings (list 3) }}`, "[1 b a 3]", ""},
+		{"reverse", `{{ list 1 2 3 | reverse }}`, "[3 2 1]", ""},
+		{"uniq", `{{ list 1 1 2 1 | uniq }}`, "[1 2]", ""},
+		{"has", `{{ list 1 2 | has 2 }} {{ list 1 2 | has 3 }}`, "true false", ""},
+		{"without", `{{ without (list 1 2 3 2) 2 }}`, "[1 3]", ""},
+		{"compact", `{{ list 0 "" "a" nil | compact }}`, "[a]", ""},
+		{"sortAlpha", `{{ .strings | sortAlpha }} {{ .strings }}`, "[a b] [b a]", ""},
+		{"slice", `{{ slice (list 1 2 3) 1 }}`, "[2 3]", ""},
+		{"slice with end", `{{ slice (list 1 2 3) 1 2 }}`, "[2]", ""},
+		{"slice out of bounds", `{{ slice (list 1 2 3) 2 5 }}`, "", "out of range"},
+		{"slice with negative start", `{{ slice (list 1 2 3) -1 }}`, "", "out of range"},
+		{"until", `{{ until 3 }}`, "[0 1 2]", ""},
+	}, data)
+}
+
+func TestDictFunctions(t *testing.T) {
+	runLibraryTests(t, []libraryTest{
+		{"dict", `{{ dict "a" 1 "b" 2 }}`, "map[a:1 b:2]", ""},
+		{"dict with odd arguments", `{{ dict "a" }}`, "map[a:<nil>]", ""},
+		{"get", `{{ get (dict "a" 1) "a" }}`, "1", ""},
+		{"set", `{{ set (dict) "a" 1 }}`, "map[a:1]", ""},
+		{"unset", `{{ unset (dict "a" 1 "b" 2) "a" }}`, "map[b:2]", ""},
+		{"hasKey", `{{ hasKey (dict "a" 1) "a" }} {{ hasKey (dict "a" 1) "b" }}`, "true false", ""},
+		{"keys", `{{ keys (dict "b" 1 "a" 2) (dict "c" 3) }}`, "[a b c]", ""},
+		{"values", `{{ values (dict "b" 1 "a" 2) }}`, "[2 1]", ""},
+		{"pick", `{{ pick (dict "a" 1 "b" 2) "a" "c" }}`, "map[a:1]", ""},
+		{"omit", `{{ omit (dict "a" 1 "b" 2) "a" }}`, "map[b:2]", ""},
+		{"merge keeps the first values", `{{ merge (dict "a" 1) (dict "a" 2 "b" 3) }}`, "map[a:1 b:3]", ""},
+		{"merge is deep", `{{ merge (dict "n" (dict "x" 1)) (dict "n" (dict "x" 2 "y" 3)) }}`, "map[n:map[x:1 y:3]]", ""},
+		{"pluck", `{{ pluck "a" (dict "a" 1) (dict "b" 2) (dict "a" 3) }}`, "[1 3]", ""},
+	}, nil)
+}
+
+func TestDictFunctionsKeepTheirInput(t *testing.T) {
+	data := map[string]interface{}{"d": map[string]interface{}{"a": 1, "n": map[string]interface{}{"x": 1}}}
+	runLibraryTests(t, []libraryTest{
+		{"set", `{{ set .d "b" 2 }} {{ .d }}`, "map[a:1 b:2 n:map[x:1]] map[a:1 n:map[x:1]]", ""},
+		{"unset", `{{ unset .d "a" }} {{ .d }}`, "map[n:map[x:1]] map[a:1 n:map[x:1]]", ""},
+		{"merge", `{{ merge .d (dict "b" 2 "n" (dict "y" 2)) }} {{ .d }}`, "map[a:1 b:2 n:map[x:1 y:2]] map[a:1 n:map[x:1]]", ""},
+	}, data)
+}
+
+func TestEncodingFunctions(t *testing.T) {
+	runLibraryTests(t, []libraryTest{
+		{"b64enc", `{{ "hello" | b64enc }}`, "aGVsbG8=", ""},
+		{"b64dec", `{{ "aGVsbG8=" | b64dec }}`, "hello", ""},
+		{"b64dec of invalid", `{{ "!" | b64dec }}`, "", "illegal base64"},
+		{"toJson", `{{ dict "a" "<b>" | toJson }}`, `{"a":"<b>"}`, ""},
+		{"toPrettyJson", `{{ dict "a" 1 | toPrettyJson }}`, "{\n  \"a\": 1\n}", ""},
+		{"fromJson", `{{ (fromJson "{\"a\":[1,2]}").a }}`, "[1 2]", ""},
+		{"fromJson of invalid", `{{ fromJson "{" }}`, "", "unexpected end of JSON input"},
+		{"sha1sum", `{{ "abc" | sha1sum }}`, "a9993e364706816aba3e25717850c26c9cd0d89d", ""},
+		{"sha256sum", `{{ "abc" | sha256sum }}`, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", ""},
+		{"sha512sum", `{{ "abc" | sha512sum }}`, "ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f", ""},
+		{"md5sum", `{{ "abc" | md5sum }}`, "900150983cd24fb0d6963f7d28e17f72", ""},
+		{"adler32sum", `{{ "abc" | adler32sum }}`, "38600999", ""},
+	}, nil)
+}
+
+func TestUUIDv4(t *testing.T) {
+	format := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	got, err := execute(`{{ uuidv4 }} {{ uuidv4 }}`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuids := strings.Fields(got)
+	for _, u := range uuids {
+		if !format.MatchString(u) {
+			t.Errorf("%s is not a version 4 UUID", u)
+		}
+	}
+	if uuids[0] == uuids[1] {
+		t.Errorf("uuidv4 returned %s twice", uuids[0])
+	}
+}
+
+func TestRegexFunctions(t *testing.T) {
+	runLibraryTests(t, []libraryTest{
+		{"regexMatch", `{{ regexMatch "^a.c$" "abc" }}`, "true", ""},
+		{"regexMatch with bad regex", `{{ regexMatch "(" "a" }}`, "", "missing closing )"},
+		{"regexFind", `{{ regexFind "[0-9]+" "ab12cd34" }}`, "12", ""},
+		{"regexFind with bad regex", `{{ regexFind "[" "a" }}`, "", "missing closing ]"},
+		{"regexFindAll", `{{ regexFindAll "[0-9]+" "a1b22c333" 2 }}`, "[1 22]", ""},
+		{"regexFindAll with bad regex", `{{ regexFindAll "(" "a" -1 }}`, "", "missing closing )"},
+		{"regexReplaceAll", `{{ regexReplaceAll "a(x*)b" "-ab-axxb-" "${1}W" }}`, "-W-xxW-", ""},
+		{"regexReplaceAll with bad regex", `{{ regexReplaceAll "(" "a" "b" }}`, "", "missing closing )"},
+		{"regexReplaceAllLiteral", `{{ regexReplaceAllLiteral "a(x*)b" "-ab-axxb-" "${1}" }}`, "-${1}-${1}-", ""},
+		{"regexSplit", `{{ regexSplit "[,;]" "a,b;c" -1 }}`, "[a b c]", ""},
+		{"regexSplit with bad regex", `{{ regexSplit "(" "a" -1 }}`, "", "missing closing )"},
+		{"regexQuoteMeta", `{{ regexQuoteMeta "a.b" }}`, `a\.b`, ""},
+	}, nil)
+}
+
+func TestEnvFunctions(t *testing.T) {
+	t.Setenv("TEMPLATE_LIBRARY_TEST", "value")
+	t.Setenv("LIBRARY_TEST", "secret")
+	runLibraryTests(t, []libraryTest{
+		{"env", `{{ env "TEMPLATE_LIBRARY_TEST" }}`, "value", ""},
+		{"env of unset variable", `{{ env "TEMPLATE_LIBRARY_TEST_UNSET" }}`, "", ""},
+		{"env without prefix", `{{ env "LIBRARY_TEST" }}`, "", ""},
+		{"expandenv", `{{ expandenv "x-$TEMPLATE_LIBRARY_TEST" }}`, "x-value", ""},
+		{"expandenv without prefix", `{{ expandenv "x-${LIBRARY_TEST}" }}`, "x-", ""},
+	}, nil)
+}
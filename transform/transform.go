@@ -0,0 +1,166 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+const (
+	defaultTimeout = 100 * time.Millisecond
+	// defaultMemoryBudget is the number of allocations (of elements of lists, maps,
+	// strings...) an expression may do.
+	defaultMemoryBudget = 1000000
+	maxNodes            = 10000
+	// maxAbandonedRuns is the number of timed out evaluations which may still be
+	// running, the expr virtual machine cannot be interrupted.
+	maxAbandonedRuns = 64
+)
+
+// abandoned counts the timed out evaluations still running.
+var abandoned atomic.Int32
+
+// Configuration describes an expression (https://expr-lang.org) run on each input
+// element, element being available as elem. The expression returns the new element,
+// nil to drop it or a list to split it into several elements.
+type Configuration struct {
+	Expression   string `config:"expression"`
+	File         string `config:"file"`
+	Timeout      string `config:"timeout"`
+	MemoryBudget uint   `config:"memoryBudget"`
+}
+
+type env struct {
+	Elem interface{} `expr:"elem"`
+}
+
+type Transformer struct {
+	program      *vm.Program
+	timeout      time.Duration
+	memoryBudget uint
+}
+
+func NewTransformer(cfg *Configuration) (*Transformer, error) {
+	source := cfg.Expression
+	if cfg.File != "" {
+		if source != "" {
+			return nil, errors.New("expression and file cannot be both set")
+		}
+		b, err := os.ReadFile(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		source = string(b)
+	}
+	if source == "" {
+		return nil, errors.New("expression or file is required")
+	}
+	t := &Transformer{timeout: defaultTimeout, memoryBudget: defaultMemoryBudget}
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout: %s", err.Error())
+		}
+		t.timeout = d
+	}
+	if cfg.MemoryBudget > 0 {
+		t.memoryBudget = cfg.MemoryBudget
+	}
+	program, err := expr.Compile(source,
+		expr.Env(env{}),
+		expr.MaxNodes(maxNodes),
+		expr.Function("merge", merge),
+		expr.Function("omit", omit),
+	)
+	if err != nil {
+		return nil, err
+	}
+	t.program = program
+	return t, nil
+}
+
+// Transform runs the expression on the element and returns the resulting elements.
+// Expressions cannot loop forever, but a long running one is abandoned after the
+// timeout, its result being ignored. Elements are rejected while too many abandoned
+// evaluations are still running.
+func (t *Transformer) Transform(elem interface{}) ([]interface{}, error) {
+	if abandoned.Load() >= maxAbandonedRuns {
+		return nil, errors.New("transform: too many timed out expressions are still running")
+	}
+	type result struct {
+		value interface{}
+		err   error
+	}
+	done := make(chan result, 1)
+	// settled is set by whichever of the evaluation or its timeout comes first
+	var settled atomic.Bool
+	go func() {
+		machine := vm.VM{MemoryBudget: t.memoryBudget}
+		v, err := machine.Run(t.program, env{Elem: elem})
+		if !settled.CompareAndSwap(false, true) {
+			abandoned.Add(-1)
+		}
+		done <- result{value: v, err: err}
+	}()
+	var r result
+	select {
+	case r = <-done:
+	case <-time.After(t.timeout):
+		if settled.CompareAndSwap(false, true) {
+			abandoned.Add(1)
+			return nil, fmt.Errorf("transform: timeout after %s", t.timeout)
+		}
+		r = <-done
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("transform: %s", r.err.Error())
+	}
+	switch v := r.value.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		return v, nil
+	}
+	return []interface{}{r.value}, nil
+}
+
+// merge returns a new map holding the entries of all the given maps, the last ones
+// overriding the first ones.
+func merge(params ...interface{}) (interface{}, error) {
+	m := make(map[string]interface{})
+	for _, p := range params {
+		if p == nil {
+			continue
+		}
+		v := reflect.ValueOf(p)
+		if v.Kind() != reflect.Map {
+			return nil, fmt.Errorf("merge: %T is not a map", p)
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = iter.Value().Interface()
+		}
+	}
+	return m, nil
+}
+
+// omit returns a copy of the map without the given keys.
+func omit(params ...interface{}) (interface{}, error) {
+	if len(params) == 0 {
+		return nil, errors.New("omit: a map is required")
+	}
+	m, err := merge(params[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range params[1:] {
+		delete(m.(map[string]interface{}), fmt.Sprint(key))
+	}
+	return m, nil
+}
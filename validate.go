@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/skilld-labs/http-event-adapter/adapter"
+	"github.com/skilld-labs/http-event-adapter/auth"
+	"github.com/skilld-labs/http-event-adapter/configuration"
+	"github.com/skilld-labs/http-event-adapter/log"
+)
+
+// configurationErrors lists all the problems of a configuration.
+type configurationErrors []error
+
+func (e configurationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// validateCommand implements the validate subcommand, it prints all the problems of
+// the configuration and returns the exit code.
+func validateCommand(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	options := addConfigFlags(flags)
+	flags.Parse(args)
+
+	l := log.NewJsonLogger(&log.LoggerConfiguration{Verbosity: log.Fatal})
+	cfg, err := options.load(l)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	errs := validateConfiguration(l, cfg)
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+	if len(errs) > 0 {
+		fmt.Fprintf(os.Stderr, "%s: %d problem(s) found\n", options.file, len(errs))
+		return 1
+	}
+	fmt.Printf("%s: configuration is valid\n", options.file)
+	return 0
+}
+
+// validateConfiguration returns all the problems found in the configuration, each one
+// being prefixed by its config path.
+func validateConfiguration(l log.Logger, cfg configuration.Provider) configurationErrors {
+	var errs configurationErrors
+	fail := func(path string, err error) {
+		var fieldErr *adapter.FieldError
+		if errors.As(err, &fieldErr) {
+			path, err = path+"."+fieldErr.Field, fieldErr.Err
+		}
+		errs = append(errs, fmt.Errorf("%s: %s", path, err.Error()))
+	}
+	if _, err := configuration.ParseSize(cfg.GetString("maxBodySize")); err != nil {
+		fail("maxBodySize", err)
+	}
+	// strict loading stops on unknown keys, values are loaded separately so that
+	// the other problems are also reported
+	var globalAuth *auth.Configuration
+	for _, err := range unknownKeys(cfg.LoadStrict("auth", &globalAuth)) {
+		fail("auth", err)
+	}
+	globalAuth = nil
+	if err := cfg.Load("auth", &globalAuth); err != nil {
+		fail("auth", err)
+	}
+	if _, err := auth.NewAuthenticator(globalAuth); err != nil {
+		fail("auth", err)
+	}
+	events := make(map[string]*adapter.EventConfiguration)
+	for _, err := range unknownKeys(cfg.LoadStrict("events", &events)) {
+		fail("events", err)
+	}
+	events = make(map[string]*adapter.EventConfiguration)
+	if err := cfg.Load("events", &events); err != nil {
+		fail("events", err)
+	}
+	if len(events) == 0 {
+		fail("events", errors.New("no event configuration"))
+	}
+	a, err := newAdapter(l, cfg)
+	if err != nil {
+		fail("events", err)
+		return errs
+	}
+	for path, event := range events {
+		for _, err := range a.Validate(event) {
+			fail("events."+path, err)
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errs
+}
+
+// unknownKeys splits the errors returned by a strict configuration loading.
+func unknownKeys(err error) []error {
+	if err == nil {
+		return nil
+	}
+	var decodeErr *mapstructure.Error
+	if !errors.As(err, &decodeErr) {
+		return []error{err}
+	}
+	errs := make([]error, len(decodeErr.Errors))
+	for i, e := range decodeErr.Errors {
+		errs[i] = errors.New(e)
+	}
+	return errs
+}
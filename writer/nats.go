@@ -1,6 +1,9 @@
 package writer
 
 import (
+	"errors"
+	"sync"
+
 	"github.com/skilld-labs/http-event-adapter/log"
 
 	nats "github.com/nats-io/nats.go"
@@ -8,28 +11,131 @@ import (
 
 type natsWriter struct {
 	logger log.Logger
-	nats   *nats.Conn
+
+	mu       sync.RWMutex
+	nats     *nats.Conn
+	settings natsSettings
+
+	// pending is the connection made with new settings, it replaces the current one
+	// when committed.
+	pending         *nats.Conn
+	pendingSettings natsSettings
 }
 
-var w *natsWriter
+// natsSettings are the connection settings, the connection is replaced when they
+// change on reload.
+type natsSettings struct {
+	url         string
+	token       string
+	user        string
+	password    string
+	credentials string
+}
 
+var (
+	natsMu sync.Mutex
+	w      *natsWriter
+)
+
+func init() {
+	Register("nats", NewNatsWriter)
+}
+
+// NewNatsWriter returns the nats writer shared by all events. If the connection
+// settings have changed since the writer was created, a new connection is staged, it
+// replaces the current one, which is drained, when committed.
 func NewNatsWriter(cfg *WriterConfiguration) (Writer, error) {
-	if w != nil {
+	settings := natsSettings{
+		url:         cfg.Config.GetString("nats.url"),
+		token:       cfg.Config.GetString("nats.token"),
+		user:        cfg.Config.GetString("nats.user"),
+		password:    cfg.Config.GetString("nats.password"),
+		credentials: cfg.Config.GetString("nats.credentials"),
+	}
+	natsMu.Lock()
+	defer natsMu.Unlock()
+	if w == nil {
+		conn, err := settings.connect()
+		if err != nil {
+			return nil, err
+		}
+		w = &natsWriter{
+			logger:   cfg.Logger,
+			nats:     conn,
+			settings: settings,
+		}
+		return w, nil
+	}
+	if w.pending == nil && w.settings == settings || w.pending != nil && w.pendingSettings == settings {
 		return w, nil
 	}
-	nats, err := nats.Connect(cfg.Config.GetString("nats.url"))
+	conn, err := settings.connect()
 	if err != nil {
 		return nil, err
 	}
-	w = &natsWriter{
-		logger: cfg.Logger,
-		nats:   nats,
+	if w.pending != nil {
+		w.pending.Close()
 	}
+	w.pending, w.pendingSettings = conn, settings
 	return w, nil
 }
 
+// Commit replaces the connection by the staged one, if any.
+func (w *natsWriter) Commit() {
+	natsMu.Lock()
+	defer natsMu.Unlock()
+	if w.pending == nil {
+		return
+	}
+	w.mu.Lock()
+	previous := w.nats
+	w.nats, w.settings = w.pending, w.pendingSettings
+	w.mu.Unlock()
+	w.pending = nil
+	w.logger.Info("nats connection settings have changed, connected to %s", w.settings.url)
+	if err := previous.Drain(); err != nil {
+		w.logger.Err("cannot drain previous nats connection (err : %s)", err.Error())
+	}
+}
+
+// Discard closes the staged connection, if any.
+func (w *natsWriter) Discard() {
+	natsMu.Lock()
+	defer natsMu.Unlock()
+	if w.pending == nil {
+		return
+	}
+	w.pending.Close()
+	w.pending = nil
+}
+
+func (s natsSettings) connect() (*nats.Conn, error) {
+	var options []nats.Option
+	if s.token != "" {
+		options = append(options, nats.Token(s.token))
+	}
+	if s.user != "" {
+		options = append(options, nats.UserInfo(s.user, s.password))
+	}
+	if s.credentials != "" {
+		options = append(options, nats.UserCredentials(s.credentials))
+	}
+	return nats.Connect(s.url, options...)
+}
+
 func (w *natsWriter) Write(channel string, data []byte) error {
-	if err := w.nats.Publish(channel, data); err != nil {
+	w.mu.RLock()
+	conn := w.nats
+	w.mu.RUnlock()
+	err := conn.Publish(channel, data)
+	if errors.Is(err, nats.ErrConnectionDraining) || errors.Is(err, nats.ErrConnectionClosed) {
+		// the connection has just been replaced
+		w.mu.RLock()
+		conn = w.nats
+		w.mu.RUnlock()
+		err = conn.Publish(channel, data)
+	}
+	if err != nil {
 		return err
 	}
 	w.logger.Debug("an event has been sent in nats (subject: %s)", channel)
@@ -2,6 +2,7 @@ package writer
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/skilld-labs/http-event-adapter/configuration"
 	"github.com/skilld-labs/http-event-adapter/log"
@@ -16,14 +17,90 @@ type Writer interface {
 	Write(channel string, data []byte) error
 }
 
+// Stager is implemented by writers whose factory stages changes, such as a new
+// connection, which must only be applied once all the routes of the configuration
+// have been built.
+type Stager interface {
+	Commit()
+	Discard()
+}
+
+// Factory creates a writer, it is called for each event using the writer.
+type Factory func(*WriterConfiguration) (Writer, error)
+
+var (
+	mu        sync.RWMutex
+	factories = make(map[string]Factory)
+
+	stagedMu sync.Mutex
+	staged   = make(map[Stager]bool)
+)
+
+// Register makes a writer available under the given outputWriter name. It is meant
+// to be called from the init function of the package providing the writer, and
+// panics if the name is already registered.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if factory == nil {
+		panic("writer: Register factory is nil for " + name)
+	}
+	if _, exists := factories[name]; exists {
+		panic("writer: Register called twice for " + name)
+	}
+	factories[name] = factory
+}
+
 func GetWriter(cfg *WriterConfiguration, name string) (Writer, error) {
-	var writer Writer
-	var err error
-	switch name {
-	case "nats":
-		writer, err = NewNatsWriter(cfg)
-	default:
-		err = fmt.Errorf("unknown writer name %s", name)
-	}
-	return writer, err
+	mu.RLock()
+	factory, exists := factories[name]
+	mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("unknown writer name %s", name)
+	}
+	w, err := factory(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := w.(Stager); ok {
+		stagedMu.Lock()
+		staged[s] = true
+		stagedMu.Unlock()
+	}
+	return w, nil
+}
+
+// Commit applies the changes staged by the writers created since the last Commit or
+// Discard, it is called once the routes using them are in place.
+func Commit() {
+	for _, s := range takeStaged() {
+		s.Commit()
+	}
+}
+
+// Discard drops the changes staged by the writers created since the last Commit or
+// Discard, it is called when the routes using them cannot be built.
+func Discard() {
+	for _, s := range takeStaged() {
+		s.Discard()
+	}
+}
+
+func takeStaged() []Stager {
+	stagedMu.Lock()
+	defer stagedMu.Unlock()
+	stagers := make([]Stager, 0, len(staged))
+	for s := range staged {
+		stagers = append(stagers, s)
+	}
+	staged = make(map[Stager]bool)
+	return stagers
+}
+
+// Exists reports whether a writer is known under the given name, without creating it.
+func Exists(name string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	_, exists := factories[name]
+	return exists
 }